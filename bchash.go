@@ -0,0 +1,136 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package simonspeck
+
+import (
+	"encoding/binary"
+	"hash"
+)
+
+// The hash functions in this file turn a block cipher into a
+// compression function and iterate it with Merkle-Damgård
+// strengthening: the message is padded with a 1 bit, zeros, and its
+// length in bits as a 64-bit big-endian integer, which may span more
+// than one message block for the small variants. The digest is as
+// long as the cipher's block.
+//
+// SECURITY NOTE: a hash with an n-bit digest offers at most n/2 bits
+// of collision resistance, i.e. 16 bits for Simon32 and 64 bits for
+// Simon128. Only the 128-bit block variants are reasonable for
+// anything beyond checksums, and even those fall short of what is
+// expected for signatures; see NewHirose for a 256-bit digest.
+
+// hashIV is the initial chaining value, the leading bytes of the
+// fractional part of pi.
+var hashIV = []byte{
+	0x24, 0x3f, 0x6a, 0x88, 0x85, 0xa3, 0x08, 0xd3,
+	0x13, 0x19, 0x8a, 0x2e, 0x03, 0x70, 0x73, 0x44,
+	0xa4, 0x09, 0x38, 0x22, 0x29, 0x9f, 0x31, 0xd0,
+	0x08, 0x2e, 0xfa, 0x98, 0xec, 0x4e, 0x6c, 0x89,
+}
+
+// mdDigest is a Merkle-Damgård iteration of a compression function.
+type mdDigest struct {
+	iv       []byte
+	h        []byte
+	blockLen int // message block length
+	buf      []byte
+	n        int
+	length   uint64
+	compress func(h, m []byte) // updates h in place
+}
+
+func newMDDigest(iv []byte, blockLen int, compress func(h, m []byte)) *mdDigest {
+	d := &mdDigest{
+		iv:       iv,
+		h:        make([]byte, len(iv)),
+		blockLen: blockLen,
+		buf:      make([]byte, blockLen),
+		compress: compress,
+	}
+	d.Reset()
+	return d
+}
+
+func (d *mdDigest) Size() int      { return len(d.h) }
+func (d *mdDigest) BlockSize() int { return d.blockLen }
+
+func (d *mdDigest) Reset() {
+	copy(d.h, d.iv)
+	d.n = 0
+	d.length = 0
+}
+
+func (d *mdDigest) Write(p []byte) (int, error) {
+	written := len(p)
+	d.length += uint64(len(p))
+	for len(p) > 0 {
+		c := copy(d.buf[d.n:], p)
+		d.n += c
+		p = p[c:]
+		if d.n == d.blockLen {
+			d.compress(d.h, d.buf)
+			d.n = 0
+		}
+	}
+	return written, nil
+}
+
+func (d *mdDigest) Sum(in []byte) []byte {
+	// Work on a copy so that the caller can keep writing.
+	e := *d
+	e.h = append([]byte(nil), d.h...)
+	e.buf = append([]byte(nil), d.buf...)
+	bits := d.length * 8
+	pad := []byte{0x80}
+	for (d.n+len(pad)+8)%d.blockLen != 0 {
+		pad = append(pad, 0)
+	}
+	e.Write(binary.BigEndian.AppendUint64(pad, bits))
+	return append(in, e.h...)
+}
+
+// cipherKey returns the chaining value h padded with zeros to a key,
+// the function g of the MMO and Miyaguchi-Preneel constructions.
+func cipherKey(v Variant, h []byte) []byte {
+	key := make([]byte, v.KeySize)
+	copy(key, h)
+	return key
+}
+
+// NewDaviesMeyer returns a hash.Hash that iterates the Davies-Meyer
+// compression function H' = E_m(H) XOR H over the given variant. Each
+// message block is a cipher key, so the block length is the variant's
+// key size, and every block costs a key expansion.
+func NewDaviesMeyer(v Variant) hash.Hash {
+	return newMDDigest(hashIV[:v.BlockSize], v.KeySize, func(h, m []byte) {
+		out := make([]byte, len(h))
+		v.New(m).Encrypt(out, h)
+		xorBytes(h, h, out)
+	})
+}
+
+// NewMMO returns a hash.Hash that iterates the Matyas-Meyer-Oseas
+// compression function H' = E_g(H)(m) XOR m over the given variant,
+// where g pads the chaining value with zeros to a key.
+func NewMMO(v Variant) hash.Hash {
+	return newMDDigest(hashIV[:v.BlockSize], v.BlockSize, func(h, m []byte) {
+		v.New(cipherKey(v, h)).Encrypt(h, m)
+		xorBytes(h, h, m)
+	})
+}
+
+// NewMiyaguchiPreneel returns a hash.Hash that iterates the
+// Miyaguchi-Preneel compression function H' = E_g(H)(m) XOR m XOR H
+// over the given variant, where g pads the chaining value with zeros
+// to a key.
+func NewMiyaguchiPreneel(v Variant) hash.Hash {
+	return newMDDigest(hashIV[:v.BlockSize], v.BlockSize, func(h, m []byte) {
+		out := make([]byte, len(h))
+		v.New(cipherKey(v, h)).Encrypt(out, m)
+		xorBytes(h, h, out)
+		xorBytes(h, h, m)
+	})
+}
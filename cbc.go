@@ -0,0 +1,229 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package simonspeck
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+)
+
+var (
+	// ErrCBCLength is returned for a message whose length the mode cannot process.
+	ErrCBCLength = errors.New("simonspeck: invalid CBC message length")
+	// ErrCBCPadding is returned when decrypted padding is malformed.
+	ErrCBCPadding = errors.New("simonspeck: invalid CBC padding")
+)
+
+// CBCMode selects how a CBC handles messages that are not a multiple
+// of the block length.
+type CBCMode int
+
+const (
+	// CBCPKCS7 appends n bytes of value n, 1 <= n <= block length.
+	CBCPKCS7 CBCMode = iota
+	// CBCISO7816 appends 0x80 and then as many zeros as needed
+	// (ISO/IEC 7816-4, also padding method 2 of ISO/IEC 9797-1).
+	CBCISO7816
+	// CBCZeroPadding appends zeros only if needed. It cannot be removed
+	// unambiguously: trailing zeros of the plaintext are lost.
+	CBCZeroPadding
+	// CBCCS1, CBCCS2 and CBCCS3 are the ciphertext stealing variants
+	// of NIST SP 800-38A Addendum. The ciphertext is as long as the
+	// plaintext, which must be at least one block. CS1 keeps the
+	// blocks in order, CS2 swaps the last two blocks only if the last
+	// one is partial, and CS3, the Kerberos variant, always swaps them.
+	CBCCS1
+	CBCCS2
+	CBCCS3
+)
+
+// CBC encrypts messages of any length in cipher block chaining mode.
+// This is mostly useful with the 48- and 96-bit block ciphers, which
+// rarely divide a message evenly. A CBC is safe for concurrent use.
+//
+// CBC provides no integrity. Decryption checks padding in constant
+// time and reports every bad padding with the same ErrCBCPadding, but
+// an attacker who can submit ciphertexts can still learn something
+// from timing further up the stack; authenticate ciphertexts, for
+// example with NewCMAC, before decrypting them.
+type CBC struct {
+	block cipher.Block
+	mode  CBCMode
+}
+
+// NewCBC creates and returns a new CBC over block.
+func NewCBC(block cipher.Block, mode CBCMode) *CBC {
+	if mode < CBCPKCS7 || mode > CBCCS3 {
+		panic("NewCBC() requires a known CBCMode")
+	}
+	return &CBC{block: block, mode: mode}
+}
+
+func (c *CBC) stealing() bool { return c.mode >= CBCCS1 }
+
+// swapped reports whether the last two ciphertext blocks are stored in
+// reverse order, given the length d of the last plaintext block.
+func (c *CBC) swapped(d int) bool {
+	return c.mode == CBCCS3 || c.mode == CBCCS2 && d != c.block.BlockSize()
+}
+
+func (c *CBC) checkIV(iv []byte) {
+	if len(iv) != c.block.BlockSize() {
+		panic("simonspeck: CBC IV length must equal block size")
+	}
+}
+
+// Encrypt encrypts plaintext under iv, which must be one block long
+// and unpredictable, and appends the result to dst. With ciphertext
+// stealing, a plaintext shorter than one block is rejected with
+// ErrCBCLength.
+func (c *CBC) Encrypt(dst, iv, plaintext []byte) ([]byte, error) {
+	c.checkIV(iv)
+	bs := c.block.BlockSize()
+	if !c.stealing() {
+		padded := c.pad(plaintext)
+		ret, out := sliceForAppend(dst, len(padded))
+		if len(padded) > 0 {
+			cipher.NewCBCEncrypter(c.block, iv).CryptBlocks(out, padded)
+		}
+		return ret, nil
+	}
+
+	if len(plaintext) < bs {
+		return nil, ErrCBCLength
+	}
+	n := (len(plaintext) + bs - 1) / bs
+	d := len(plaintext) - (n-1)*bs
+	buf := make([]byte, n*bs)
+	copy(buf, plaintext)
+	cipher.NewCBCEncrypter(c.block, iv).CryptBlocks(buf, buf)
+	ret, out := sliceForAppend(dst, len(plaintext))
+	if n == 1 {
+		copy(out, buf)
+		return ret, nil
+	}
+	// Drop the trailing bs-d bytes of the penultimate block; they can
+	// be recovered from the last one.
+	prev, last := buf[(n-2)*bs:(n-1)*bs], buf[(n-1)*bs:]
+	copy(out, buf[:(n-2)*bs])
+	tail := out[(n-2)*bs:]
+	if c.swapped(d) {
+		copy(tail, last)
+		copy(tail[bs:], prev[:d])
+	} else {
+		copy(tail, prev[:d])
+		copy(tail[d:], last)
+	}
+	return ret, nil
+}
+
+// Decrypt decrypts ciphertext under iv and appends the plaintext to
+// dst. It returns ErrCBCLength if the ciphertext cannot have been
+// produced by Encrypt, and ErrCBCPadding if the padding is invalid.
+func (c *CBC) Decrypt(dst, iv, ciphertext []byte) ([]byte, error) {
+	c.checkIV(iv)
+	bs := c.block.BlockSize()
+	if !c.stealing() {
+		if len(ciphertext)%bs != 0 || len(ciphertext) == 0 && c.mode != CBCZeroPadding {
+			return nil, ErrCBCLength
+		}
+		ret, out := sliceForAppend(dst, len(ciphertext))
+		if len(ciphertext) == 0 {
+			return ret, nil
+		}
+		cipher.NewCBCDecrypter(c.block, iv).CryptBlocks(out, ciphertext)
+		n, ok := c.unpad(out[len(out)-bs:])
+		if ok != 1 {
+			clear(out)
+			return nil, ErrCBCPadding
+		}
+		return ret[:len(ret)-n], nil
+	}
+
+	if len(ciphertext) < bs {
+		return nil, ErrCBCLength
+	}
+	n := (len(ciphertext) + bs - 1) / bs
+	d := len(ciphertext) - (n-1)*bs
+	buf := make([]byte, n*bs)
+	if n == 1 {
+		copy(buf, ciphertext)
+	} else {
+		copy(buf, ciphertext[:(n-2)*bs])
+		// Rebuild the full penultimate block: its head was sent and
+		// its tail is the tail of D(last), since the last plaintext
+		// block was zero-padded before it was encrypted.
+		tail := ciphertext[(n-2)*bs:]
+		prevHead, last := tail[:d], tail[d:]
+		if c.swapped(d) {
+			last, prevHead = tail[:bs], tail[bs:]
+		}
+		prev := buf[(n-2)*bs : (n-1)*bs]
+		c.block.Decrypt(prev, last)
+		copy(prev, prevHead)
+		copy(buf[(n-1)*bs:], last)
+	}
+	cipher.NewCBCDecrypter(c.block, iv).CryptBlocks(buf, buf)
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	copy(out, buf)
+	return ret, nil
+}
+
+// pad returns a copy of p padded to a multiple of the block length.
+func (c *CBC) pad(p []byte) []byte {
+	bs := c.block.BlockSize()
+	n := bs - len(p)%bs
+	if c.mode == CBCZeroPadding && n == bs {
+		n = 0
+	}
+	out := make([]byte, len(p)+n)
+	copy(out, p)
+	switch c.mode {
+	case CBCPKCS7:
+		for i := len(p); i < len(out); i++ {
+			out[i] = byte(n)
+		}
+	case CBCISO7816:
+		out[len(p)] = 0x80
+	}
+	return out
+}
+
+// unpad returns the padding length of the final block b, and ok = 1
+// if the padding is valid. Its running time depends only on len(b).
+func (c *CBC) unpad(b []byte) (n, ok int) {
+	bs := len(b)
+	switch c.mode {
+	case CBCPKCS7:
+		n = int(b[bs-1])
+		ok = subtle.ConstantTimeLessOrEq(1, n) & subtle.ConstantTimeLessOrEq(n, bs)
+		for i := 0; i < bs; i++ {
+			inPad := subtle.ConstantTimeLessOrEq(i+1, n)
+			eq := subtle.ConstantTimeByteEq(b[bs-1-i], byte(n))
+			ok &= eq | (inPad ^ 1)
+		}
+	case CBCISO7816:
+		// Scan from the end: zeros until the first 0x80 marker.
+		found, bad := 0, 0
+		for i := bs - 1; i >= 0; i-- {
+			notFound := found ^ 1
+			marker := subtle.ConstantTimeByteEq(b[i], 0x80)
+			zero := subtle.ConstantTimeByteEq(b[i], 0)
+			n = subtle.ConstantTimeSelect(notFound&marker, bs-i, n)
+			bad |= notFound & ((marker | zero) ^ 1)
+			found |= marker
+		}
+		ok = found & (bad ^ 1)
+	default:
+		seen := 0
+		for i := bs - 1; i >= 0; i-- {
+			seen |= subtle.ConstantTimeByteEq(b[i], 0) ^ 1
+			n += seen ^ 1
+		}
+		ok = 1
+	}
+	return n, ok & 1
+}
@@ -0,0 +1,426 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package simonspeck
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ChannelMaxRecord is the largest plaintext carried by one record of a
+// Channel. Longer writes are split.
+const ChannelMaxRecord = 1 << 14
+
+var (
+	// ErrChannelHandshake is returned when the peer uses another PSK, version or suite.
+	ErrChannelHandshake = errors.New("simonspeck: channel handshake failed")
+	// ErrChannelReplay is returned for a record out of sequence.
+	ErrChannelReplay = errors.New("simonspeck: replayed or reordered record")
+	// ErrChannelRecord is returned for a record with a bad type or length.
+	ErrChannelRecord = errors.New("simonspeck: malformed channel record")
+)
+
+// ChannelSuite selects the record protection of a Channel.
+type ChannelSuite byte
+
+const (
+	// SuiteSpeck128GCM seals records with GCM over Speck128/128.
+	SuiteSpeck128GCM ChannelSuite = iota + 1
+	// SuiteSpeck64EAX seals records with EAX over Speck64/128, for
+	// devices that only have the 64-bit cipher. Its tags are 8 bytes,
+	// and a 64-bit block should not encrypt much more than 2^32 blocks
+	// (32GB) under one key, so reconnect well before that.
+	SuiteSpeck64EAX
+)
+
+// ChannelConfig configures a Channel. Both ends must use the same PSK
+// and Suite.
+type ChannelConfig struct {
+	// PSK is the pre-shared key, of at least 16 bytes.
+	PSK []byte
+	// Suite is the record protection. It defaults to SuiteSpeck128GCM.
+	Suite ChannelSuite
+}
+
+// Handshake messages. Each hello is
+//
+//	"SSCH" || version || suite || 16-byte random nonce
+//
+// and the server's hello is followed by its finished message. Keys are
+// derived with CounterKDF, using CMAC over Speck128/128 keyed with the
+// PSK (see CMACPRF), with both hellos as the context. A finished
+// message is the CMAC of both hellos under a key of its own, and
+// proves knowledge of the PSK before any data is exchanged.
+const (
+	channelVersion    = 1
+	channelNonceSize  = 16
+	channelHelloSize  = 4 + 1 + 1 + channelNonceSize
+	channelKeySize    = 16
+	channelHeaderSize = 1 + 8 + 2 // type, sequence number, length
+)
+
+var channelMagic = []byte("SSCH")
+
+// Record types.
+const (
+	recordData  = 0
+	recordClose = 1
+)
+
+// Channel is a net.Conn that protects the traffic of an underlying
+// connection with a pre-shared key. Each record is
+//
+//	type || 64-bit sequence number || 16-bit length || sealed payload
+//
+// with the header as additional data and the sequence number as the
+// nonce. Each direction has its own key and counts records from zero,
+// so a replayed, reordered, dropped or reflected record is rejected.
+// A close record marks the end of the stream, so truncation is
+// detected too: Read returns io.EOF only after the peer's Close, and
+// io.ErrUnexpectedEOF if the connection ends without one.
+//
+// Like crypto/tls, the handshake runs on the first Read or Write, or
+// on an explicit call to Handshake. It does not provide forward
+// secrecy: anyone who later learns the PSK can decrypt recorded
+// traffic.
+type Channel struct {
+	conn     net.Conn
+	config   ChannelConfig
+	isClient bool
+
+	handshakeMu       sync.Mutex
+	handshakeErr      error       // sticky, guarded by handshakeMu
+	handshakeComplete atomic.Bool // set once the handshake has succeeded
+
+	// activeCall counts the Writes in progress, in steps of 2; bit 0 is
+	// set by Close. As in crypto/tls, this lets Close skip the close
+	// record, rather than wait on a blocked Write.
+	activeCall atomic.Int32
+
+	in, out halfChannel
+}
+
+type halfChannel struct {
+	sync.Mutex
+	aead cipher.AEAD
+	seq  uint64
+	err  error  // sticky
+	buf  []byte // decrypted data not yet read
+}
+
+// ChannelClient returns a new client side Channel over conn.
+func ChannelClient(conn net.Conn, config *ChannelConfig) *Channel {
+	return newChannel(conn, config, true)
+}
+
+// ChannelServer returns a new server side Channel over conn.
+func ChannelServer(conn net.Conn, config *ChannelConfig) *Channel {
+	return newChannel(conn, config, false)
+}
+
+func newChannel(conn net.Conn, config *ChannelConfig, isClient bool) *Channel {
+	if len(config.PSK) < 16 {
+		panic("simonspeck: channel PSK must be at least 16 bytes")
+	}
+	c := &Channel{conn: conn, config: *config, isClient: isClient}
+	c.config.PSK = append([]byte(nil), config.PSK...)
+	switch c.config.Suite {
+	case 0:
+		c.config.Suite = SuiteSpeck128GCM
+	case SuiteSpeck128GCM, SuiteSpeck64EAX:
+	default:
+		panic("simonspeck: unknown channel suite")
+	}
+	return c
+}
+
+// Handshake runs the handshake if it has not yet been run. On failure
+// the underlying connection is closed, and the error is returned by
+// every later call.
+func (c *Channel) Handshake() error {
+	if c.handshakeComplete.Load() {
+		return nil
+	}
+	c.handshakeMu.Lock()
+	defer c.handshakeMu.Unlock()
+	if c.handshakeErr != nil || c.handshakeComplete.Load() {
+		return c.handshakeErr
+	}
+	if c.isClient {
+		c.handshakeErr = c.clientHandshake()
+	} else {
+		c.handshakeErr = c.serverHandshake()
+	}
+	if c.handshakeErr != nil {
+		c.conn.Close()
+		return c.handshakeErr
+	}
+	c.handshakeComplete.Store(true)
+	return nil
+}
+
+func (c *Channel) hello() ([]byte, error) {
+	h := make([]byte, 0, channelHelloSize)
+	h = append(h, channelMagic...)
+	h = append(h, channelVersion, byte(c.config.Suite))
+	h = h[:channelHelloSize]
+	if _, err := rand.Read(h[6:]); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (c *Channel) readHello() ([]byte, error) {
+	h := make([]byte, channelHelloSize)
+	if _, err := io.ReadFull(c.conn, h); err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(h[:4], channelMagic) != 1 || h[4] != channelVersion || h[5] != byte(c.config.Suite) {
+		return nil, ErrChannelHandshake
+	}
+	return h, nil
+}
+
+func (c *Channel) clientHandshake() error {
+	ch, err := c.hello()
+	if err != nil {
+		return err
+	}
+	if _, err := c.conn.Write(ch); err != nil {
+		return err
+	}
+	sh, err := c.readHello()
+	if err != nil {
+		return err
+	}
+	keys := c.deriveKeys(ch, sh)
+	serverFinished := make([]byte, 16)
+	if _, err := io.ReadFull(c.conn, serverFinished); err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(serverFinished, keys.serverFinished) != 1 {
+		return ErrChannelHandshake
+	}
+	if _, err := c.conn.Write(keys.clientFinished); err != nil {
+		return err
+	}
+	c.out.aead, c.in.aead = keys.clientWrite, keys.serverWrite
+	return nil
+}
+
+func (c *Channel) serverHandshake() error {
+	ch, err := c.readHello()
+	if err != nil {
+		return err
+	}
+	sh, err := c.hello()
+	if err != nil {
+		return err
+	}
+	keys := c.deriveKeys(ch, sh)
+	if _, err := c.conn.Write(append(sh, keys.serverFinished...)); err != nil {
+		return err
+	}
+	clientFinished := make([]byte, 16)
+	if _, err := io.ReadFull(c.conn, clientFinished); err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(clientFinished, keys.clientFinished) != 1 {
+		return ErrChannelHandshake
+	}
+	c.out.aead, c.in.aead = keys.serverWrite, keys.clientWrite
+	return nil
+}
+
+type channelKeys struct {
+	clientWrite, serverWrite       cipher.AEAD
+	clientFinished, serverFinished []byte
+}
+
+func (c *Channel) deriveKeys(clientHello, serverHello []byte) *channelKeys {
+	v, _ := LookupVariant("Speck128/128")
+	transcript := append(append([]byte(nil), clientHello...), serverHello...)
+	k := CounterKDF(CMACPRF(v)(c.config.PSK), []byte("simonspeck channel"), transcript, 4*channelKeySize)
+	finished := func(key []byte) []byte {
+		mac := NewCMAC(NewSpeck128(key))
+		mac.Write(transcript)
+		return mac.Sum(nil)
+	}
+	return &channelKeys{
+		clientWrite:    c.newAEAD(k[0:16]),
+		serverWrite:    c.newAEAD(k[16:32]),
+		clientFinished: finished(k[32:48]),
+		serverFinished: finished(k[48:64]),
+	}
+}
+
+func (c *Channel) newAEAD(key []byte) cipher.AEAD {
+	if c.config.Suite == SuiteSpeck64EAX {
+		return NewEAXWithNonceSize(NewSpeck64(key), 8)
+	}
+	aead, err := cipher.NewGCM(NewSpeck128(key))
+	if err != nil {
+		panic(err)
+	}
+	return aead
+}
+
+// nonce returns the sequence number as a nonce, left-padded with
+// zeros to the AEAD's nonce size.
+func (h *halfChannel) nonce(seq uint64) []byte {
+	nonce := make([]byte, h.aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], seq)
+	return nonce
+}
+
+// Read reads data from the channel.
+func (c *Channel) Read(p []byte) (int, error) {
+	if err := c.Handshake(); err != nil {
+		return 0, err
+	}
+	c.in.Lock()
+	defer c.in.Unlock()
+	for len(c.in.buf) == 0 && c.in.err == nil {
+		c.in.err = c.readRecord()
+	}
+	if len(c.in.buf) > 0 {
+		n := copy(p, c.in.buf)
+		c.in.buf = c.in.buf[n:]
+		return n, nil
+	}
+	return 0, c.in.err
+}
+
+func (c *Channel) readRecord() error {
+	hdr := make([]byte, channelHeaderSize)
+	if _, err := io.ReadFull(c.conn, hdr); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	typ, seq := hdr[0], binary.BigEndian.Uint64(hdr[1:9])
+	n := int(binary.BigEndian.Uint16(hdr[9:]))
+	if typ > recordClose || n < c.in.aead.Overhead() || n > ChannelMaxRecord+c.in.aead.Overhead() {
+		return ErrChannelRecord
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(c.conn, body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if seq != c.in.seq {
+		return ErrChannelReplay
+	}
+	plaintext, err := c.in.aead.Open(body[:0], c.in.nonce(seq), body, hdr)
+	if err != nil {
+		return ErrOpen
+	}
+	c.in.seq++
+	if typ == recordClose {
+		return io.EOF
+	}
+	c.in.buf = plaintext
+	return nil
+}
+
+// Write writes data to the channel, in records of at most
+// ChannelMaxRecord bytes.
+func (c *Channel) Write(p []byte) (int, error) {
+	for {
+		x := c.activeCall.Load()
+		if x&1 != 0 {
+			return 0, net.ErrClosed
+		}
+		if c.activeCall.CompareAndSwap(x, x+2) {
+			break
+		}
+	}
+	defer c.activeCall.Add(-2)
+
+	if err := c.Handshake(); err != nil {
+		return 0, err
+	}
+	c.out.Lock()
+	defer c.out.Unlock()
+	n := 0
+	for len(p) > 0 {
+		if c.out.err != nil {
+			return n, c.out.err
+		}
+		m := min(len(p), ChannelMaxRecord)
+		c.out.err = c.writeRecord(recordData, p[:m])
+		if c.out.err == nil {
+			n += m
+		}
+		p = p[m:]
+	}
+	return n, c.out.err
+}
+
+func (c *Channel) writeRecord(typ byte, p []byte) error {
+	overhead := c.out.aead.Overhead()
+	rec := make([]byte, channelHeaderSize, channelHeaderSize+len(p)+overhead)
+	rec[0] = typ
+	binary.BigEndian.PutUint64(rec[1:9], c.out.seq)
+	binary.BigEndian.PutUint16(rec[9:], uint16(len(p)+overhead))
+	rec = c.out.aead.Seal(rec, c.out.nonce(c.out.seq), p, rec[:channelHeaderSize])
+	c.out.seq++
+	_, err := c.conn.Write(rec)
+	return err
+}
+
+// Close sends a close record, if the handshake has completed, and
+// closes the underlying connection, which unblocks any pending Read,
+// Write or Handshake. As in crypto/tls, the close record is skipped if
+// a Write is in progress, and sending it sets a write deadline five
+// seconds ahead, replacing any deadline set earlier.
+func (c *Channel) Close() error {
+	var x int32
+	for {
+		x = c.activeCall.Load()
+		if x&1 != 0 {
+			return net.ErrClosed
+		}
+		if c.activeCall.CompareAndSwap(x, x|1) {
+			break
+		}
+	}
+	if x == 0 && c.handshakeComplete.Load() {
+		c.out.Lock()
+		if c.out.err == nil {
+			c.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+			c.writeRecord(recordClose, nil)
+		}
+		c.out.err = net.ErrClosed
+		c.out.Unlock()
+	}
+	err := c.conn.Close()
+
+	// Wait for the callers that held the connection, which return
+	// promptly now that it is closed.
+	c.handshakeMu.Lock()
+	c.handshakeMu.Unlock()
+	c.out.Lock()
+	c.out.err = net.ErrClosed
+	c.out.Unlock()
+	return err
+}
+
+func (c *Channel) LocalAddr() net.Addr                { return c.conn.LocalAddr() }
+func (c *Channel) RemoteAddr() net.Addr               { return c.conn.RemoteAddr() }
+func (c *Channel) SetDeadline(t time.Time) error      { return c.conn.SetDeadline(t) }
+func (c *Channel) SetReadDeadline(t time.Time) error  { return c.conn.SetReadDeadline(t) }
+func (c *Channel) SetWriteDeadline(t time.Time) error { return c.conn.SetWriteDeadline(t) }
@@ -0,0 +1,95 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package simonspeck
+
+import (
+	"crypto/cipher"
+	"hash"
+)
+
+// cmac implements CMAC (NIST SP 800-38B, also known as OMAC1) over a
+// block cipher of any of the block lengths in this package.
+type cmac struct {
+	block  cipher.Block
+	k1, k2 []byte
+	x      []byte // chaining value
+	buf    []byte // the last, possibly full, block seen so far
+	n      int
+}
+
+// NewCMAC returns a hash.Hash computing CMAC with the given block
+// cipher. The tag is one block long; truncate it as needed.
+func NewCMAC(block cipher.Block) hash.Hash {
+	return newCMAC(block)
+}
+
+func newCMAC(block cipher.Block) *cmac {
+	bs := block.BlockSize()
+	m := &cmac{
+		block: block,
+		k1:    make([]byte, bs),
+		k2:    make([]byte, bs),
+		x:     make([]byte, bs),
+		buf:   make([]byte, bs),
+	}
+	block.Encrypt(m.k1, m.k1)
+	gfDouble(m.k1, m.k1)
+	gfDouble(m.k2, m.k1)
+	return m
+}
+
+// fresh returns a reset copy of m that shares its subkeys, which is
+// cheaper than deriving them again.
+func (m *cmac) fresh() *cmac {
+	bs := len(m.x)
+	return &cmac{
+		block: m.block,
+		k1:    m.k1,
+		k2:    m.k2,
+		x:     make([]byte, bs),
+		buf:   make([]byte, bs),
+	}
+}
+
+func (m *cmac) Size() int      { return len(m.x) }
+func (m *cmac) BlockSize() int { return len(m.x) }
+
+func (m *cmac) Reset() {
+	clear(m.x)
+	m.n = 0
+}
+
+func (m *cmac) Write(p []byte) (int, error) {
+	written := len(p)
+	bs := len(m.buf)
+	for len(p) > 0 {
+		// A full block is only processed once we know it isn't the
+		// last one, since the last block is handled by Sum.
+		if m.n == bs {
+			xorBytes(m.x, m.x, m.buf)
+			m.block.Encrypt(m.x, m.x)
+			m.n = 0
+		}
+		c := copy(m.buf[m.n:], p)
+		m.n += c
+		p = p[c:]
+	}
+	return written, nil
+}
+
+func (m *cmac) Sum(in []byte) []byte {
+	bs := len(m.buf)
+	last := make([]byte, bs)
+	copy(last, m.buf[:m.n])
+	if m.n == bs {
+		xorBytes(last, last, m.k1)
+	} else {
+		last[m.n] = 0x80
+		xorBytes(last, last, m.k2)
+	}
+	xorBytes(last, last, m.x)
+	m.block.Encrypt(last, last)
+	return append(in, last...)
+}
@@ -0,0 +1,341 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/pedroalbanese/simonspeck"
+)
+
+// An encrypted file is a header followed by sectors. Each sector
+// holds up to sectorSize bytes of plaintext sealed with the AEAD mode
+// named in the header, under a nonce made from the header nonce, the
+// sector number and a flag marking the final sector, with the whole
+// header as additional data. The header is thus authenticated by every
+// sector, and truncating, reordering or splicing sectors fails.
+//
+//	magic    "SSPK"
+//	version  1 byte
+//	alg      1-byte length, then e.g. "Speck128/256"
+//	mode     1-byte length, then "EAX" or "GCM"
+//	kdf      1-byte length, then "PBKDF2-SHA256" or "none"
+//	iter     4 bytes, big-endian
+//	salt     1-byte length, then salt
+//	sector   4 bytes, big-endian plaintext sector size
+//	nonce    1-byte length, then nonce
+const (
+	magic         = "SSPK"
+	formatVersion = 1
+
+	kdfNone   = "none"
+	kdfPBKDF2 = "PBKDF2-SHA256"
+
+	defaultSectorSize = 64 * 1024
+	maxSectorSize     = 16 * 1024 * 1024
+	eaxNonceSize      = 16
+	saltSize          = 16
+
+	// The header is read before it can be authenticated, so its
+	// PBKDF2 parameters are bounded: too few iterations would let a
+	// forged file skip the key stretching, and too many would keep dec
+	// busy for hours before authentication fails.
+	minIter = 1000
+	maxIter = 1 << 24
+)
+
+var (
+	errNotEncrypted = errors.New("not a simonspeck encrypted file")
+	errTruncated    = errors.New("encrypted file is truncated")
+)
+
+type header struct {
+	alg        string
+	mode       string
+	kdf        string
+	iter       uint32
+	salt       []byte
+	sectorSize uint32
+	nonce      []byte
+}
+
+func (h *header) marshal() []byte {
+	var b bytes.Buffer
+	b.WriteString(magic)
+	b.WriteByte(formatVersion)
+	for _, s := range [][]byte{[]byte(h.alg), []byte(h.mode), []byte(h.kdf)} {
+		b.WriteByte(byte(len(s)))
+		b.Write(s)
+	}
+	b.Write(binary.BigEndian.AppendUint32(nil, h.iter))
+	b.WriteByte(byte(len(h.salt)))
+	b.Write(h.salt)
+	b.Write(binary.BigEndian.AppendUint32(nil, h.sectorSize))
+	b.WriteByte(byte(len(h.nonce)))
+	b.Write(h.nonce)
+	return b.Bytes()
+}
+
+// readHeader parses a header from r and also returns its raw bytes,
+// which are authenticated as additional data.
+func readHeader(r *bufio.Reader) (*header, []byte, error) {
+	var raw bytes.Buffer
+	tr := io.TeeReader(r, &raw)
+	fixed := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(tr, fixed); err != nil || string(fixed[:len(magic)]) != magic {
+		return nil, nil, errNotEncrypted
+	}
+	if fixed[len(magic)] != formatVersion {
+		return nil, nil, fmt.Errorf("unsupported format version %d", fixed[len(magic)])
+	}
+	short := func(err error) error {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return errTruncated
+		}
+		return err
+	}
+	readBytes := func() ([]byte, error) {
+		var n [1]byte
+		if _, err := io.ReadFull(tr, n[:]); err != nil {
+			return nil, short(err)
+		}
+		b := make([]byte, n[0])
+		if _, err := io.ReadFull(tr, b); err != nil {
+			return nil, short(err)
+		}
+		return b, nil
+	}
+	readUint32 := func() (uint32, error) {
+		var b [4]byte
+		if _, err := io.ReadFull(tr, b[:]); err != nil {
+			return 0, short(err)
+		}
+		return binary.BigEndian.Uint32(b[:]), nil
+	}
+	h := new(header)
+	var err error
+	var f []byte
+	if f, err = readBytes(); err != nil {
+		return nil, nil, err
+	}
+	h.alg = string(f)
+	if f, err = readBytes(); err != nil {
+		return nil, nil, err
+	}
+	h.mode = string(f)
+	if f, err = readBytes(); err != nil {
+		return nil, nil, err
+	}
+	h.kdf = string(f)
+	if h.iter, err = readUint32(); err != nil {
+		return nil, nil, err
+	}
+	if h.salt, err = readBytes(); err != nil {
+		return nil, nil, err
+	}
+	if h.kdf == kdfPBKDF2 {
+		if h.iter < minIter || h.iter > maxIter {
+			return nil, nil, fmt.Errorf("PBKDF2 iteration count %d is not between %d and %d", h.iter, minIter, maxIter)
+		}
+		if len(h.salt) < saltSize {
+			return nil, nil, fmt.Errorf("PBKDF2 salt of %d bytes is too short", len(h.salt))
+		}
+	}
+	if h.sectorSize, err = readUint32(); err != nil {
+		return nil, nil, err
+	}
+	if h.sectorSize == 0 || h.sectorSize > maxSectorSize {
+		return nil, nil, fmt.Errorf("invalid sector size %d", h.sectorSize)
+	}
+	if h.nonce, err = readBytes(); err != nil {
+		return nil, nil, err
+	}
+	return h, raw.Bytes(), nil
+}
+
+// keySource is either a raw key or a password to stretch.
+type keySource struct {
+	key      []byte
+	password string
+}
+
+// newAEAD derives the key described by h and returns the AEAD it
+// names.
+func newAEAD(h *header, ks keySource) (cipher.AEAD, error) {
+	v, ok := simonspeck.LookupVariant(h.alg)
+	if !ok {
+		return nil, fmt.Errorf("unknown algorithm %q", h.alg)
+	}
+	var key []byte
+	switch h.kdf {
+	case kdfNone:
+		if ks.key == nil {
+			return nil, errors.New("file was encrypted with a raw key; use -key")
+		}
+		key = ks.key
+	case kdfPBKDF2:
+		if ks.key != nil {
+			return nil, errors.New("file was encrypted with a password; use -pass")
+		}
+		var err error
+		key, err = pbkdf2.Key(sha256.New, ks.password, h.salt, int(h.iter), v.KeySize)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown key derivation function %q", h.kdf)
+	}
+	if len(key) != v.KeySize {
+		return nil, fmt.Errorf("%s requires a %d-byte key, got %d bytes", v.Name, v.KeySize, len(key))
+	}
+	block := v.New(key)
+	switch h.mode {
+	case "EAX":
+		return simonspeck.NewEAXWithNonceSize(block, eaxNonceSize), nil
+	case "GCM":
+		if v.BlockSize != 16 {
+			return nil, fmt.Errorf("GCM requires a 128-bit block, and %s has a %d-bit block", v.Name, 8*v.BlockSize)
+		}
+		return cipher.NewGCM(block)
+	}
+	return nil, fmt.Errorf("unknown mode %q", h.mode)
+}
+
+// sectorNonce XORs the sector number and the final flag into the last
+// five bytes of the header nonce.
+func sectorNonce(base []byte, sector uint32, final bool) []byte {
+	nonce := append([]byte(nil), base...)
+	n := len(nonce)
+	nonce[n-5] ^= byte(sector >> 24)
+	nonce[n-4] ^= byte(sector >> 16)
+	nonce[n-3] ^= byte(sector >> 8)
+	nonce[n-2] ^= byte(sector)
+	if final {
+		nonce[n-1] ^= 1
+	}
+	return nonce
+}
+
+type encryptOptions struct {
+	alg        string
+	mode       string
+	iter       int
+	sectorSize int
+}
+
+func encrypt(dst io.Writer, src io.Reader, ks keySource, opts encryptOptions) error {
+	h := &header{
+		alg:        opts.alg,
+		mode:       opts.mode,
+		kdf:        kdfNone,
+		sectorSize: uint32(opts.sectorSize),
+	}
+	if h.sectorSize == 0 || h.sectorSize > maxSectorSize {
+		return fmt.Errorf("invalid sector size %d", opts.sectorSize)
+	}
+	if ks.key == nil {
+		if opts.iter < minIter || opts.iter > maxIter {
+			return fmt.Errorf("PBKDF2 iteration count must be between %d and %d", minIter, maxIter)
+		}
+		h.kdf = kdfPBKDF2
+		h.iter = uint32(opts.iter)
+		h.salt = make([]byte, saltSize)
+		if _, err := rand.Read(h.salt); err != nil {
+			return err
+		}
+	}
+	// The nonce size depends on the mode, so build the AEAD first.
+	aead, err := newAEAD(h, ks)
+	if err != nil {
+		return err
+	}
+	h.nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(h.nonce); err != nil {
+		return err
+	}
+	ad := h.marshal()
+	if _, err := dst.Write(ad); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(src)
+	buf := make([]byte, h.sectorSize)
+	for sector := uint32(0); ; sector++ {
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		_, peekErr := r.Peek(1)
+		final := peekErr != nil
+		if final && peekErr != io.EOF {
+			return peekErr
+		}
+		if sector == ^uint32(0) && !final {
+			return errors.New("input too large")
+		}
+		out := aead.Seal(nil, sectorNonce(h.nonce, sector, final), buf[:n], ad)
+		if _, err := dst.Write(out); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+	}
+}
+
+// decrypt writes only authenticated plaintext to dst, but if the file
+// turns out to be corrupt partway through, some sectors will already
+// have been written; callers should discard the output on error.
+func decrypt(dst io.Writer, src io.Reader, ks keySource) error {
+	r := bufio.NewReader(src)
+	h, ad, err := readHeader(r)
+	if err != nil {
+		return err
+	}
+	aead, err := newAEAD(h, ks)
+	if err != nil {
+		return err
+	}
+	if len(h.nonce) != aead.NonceSize() {
+		return fmt.Errorf("invalid nonce length %d for %s", len(h.nonce), h.mode)
+	}
+	buf := make([]byte, int(h.sectorSize)+aead.Overhead())
+	for sector := uint32(0); ; sector++ {
+		n, err := io.ReadFull(r, buf)
+		if err == io.EOF {
+			return errTruncated
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		_, peekErr := r.Peek(1)
+		final := peekErr != nil
+		if final && peekErr != io.EOF {
+			return peekErr
+		}
+		out, err := aead.Open(buf[:0], sectorNonce(h.nonce, sector, final), buf[:n], ad)
+		if err != nil {
+			if final {
+				return fmt.Errorf("authentication failed in sector %d: wrong key, or the file was truncated or tampered with", sector)
+			}
+			return fmt.Errorf("authentication failed in sector %d: wrong key, or the file was tampered with", sector)
+		}
+		if _, err := dst.Write(out); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+	}
+}
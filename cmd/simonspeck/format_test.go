@@ -0,0 +1,121 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	plaintext := make([]byte, 3*256+17)
+	rand.Read(plaintext)
+	for _, opts := range []encryptOptions{
+		{alg: "Speck128/256", mode: "GCM", iter: 1000, sectorSize: 256},
+		{alg: "Simon128/128", mode: "EAX", iter: 1000, sectorSize: 256},
+		{alg: "Speck32/64", mode: "EAX", iter: 1000, sectorSize: 256},
+		{alg: "Simon48/72", mode: "EAX", iter: 1000, sectorSize: 256},
+		{alg: "Speck96/144", mode: "EAX", iter: 1000, sectorSize: 256},
+	} {
+		for _, size := range []int{0, 256, len(plaintext)} {
+			ks := keySource{password: "correct horse"}
+			var enc bytes.Buffer
+			if err := encrypt(&enc, bytes.NewReader(plaintext[:size]), ks, opts); err != nil {
+				t.Fatalf("%s/%s: %v", opts.alg, opts.mode, err)
+			}
+			var dec bytes.Buffer
+			if err := decrypt(&dec, bytes.NewReader(enc.Bytes()), ks); err != nil {
+				t.Fatalf("%s/%s: %v", opts.alg, opts.mode, err)
+			}
+			if !bytes.Equal(dec.Bytes(), plaintext[:size]) {
+				t.Errorf("%s/%s: round trip of %d bytes failed", opts.alg, opts.mode, size)
+			}
+			if err := decrypt(&dec, bytes.NewReader(enc.Bytes()), keySource{password: "wrong"}); err == nil {
+				t.Errorf("%s/%s: decrypted with the wrong password", opts.alg, opts.mode)
+			}
+		}
+	}
+}
+
+func TestTampering(t *testing.T) {
+	plaintext := make([]byte, 1000)
+	key := make([]byte, 16)
+	opts := encryptOptions{alg: "Speck64/128", mode: "EAX", sectorSize: 100}
+	var enc bytes.Buffer
+	if err := encrypt(&enc, bytes.NewReader(plaintext), keySource{key: key}, opts); err != nil {
+		t.Fatal(err)
+	}
+	file := enc.Bytes()
+	hdrLen := len(file) - 10*(100+8)
+	sector := func(i int) []byte { return file[hdrLen+i*108 : hdrLen+(i+1)*108] }
+	concat := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+	cases := map[string][]byte{
+		"flipped nonce bit":   concat(file[:hdrLen-1], []byte{file[hdrLen-1] ^ 1}, file[hdrLen:]),
+		"flipped sector bit":  concat(file[:hdrLen+50], []byte{file[hdrLen+50] ^ 1}, file[hdrLen+51:]),
+		"truncated at sector": file[:len(file)-108],
+		"truncated mid file":  file[:len(file)-30],
+		"header only":         file[:hdrLen],
+		"swapped sectors":     concat(file[:hdrLen], sector(1), sector(0), file[hdrLen+216:]),
+		"appended sector":     concat(file, sector(9)),
+		"not encrypted":       []byte("hello, world"),
+	}
+	for name, data := range cases {
+		var dec bytes.Buffer
+		err := decrypt(&dec, bytes.NewReader(data), keySource{key: key})
+		if err == nil {
+			t.Errorf("%s: decryption succeeded", name)
+		} else if !strings.Contains(err.Error(), "authentication failed") &&
+			err != errTruncated && err != errNotEncrypted {
+			t.Errorf("%s: unclear error %v", name, err)
+		}
+	}
+}
+
+func TestHeaderBounds(t *testing.T) {
+	ks := keySource{password: "correct horse"}
+	opts := encryptOptions{alg: "Speck64/128", mode: "EAX", iter: minIter, sectorSize: 256}
+	var enc bytes.Buffer
+	if err := encrypt(&enc, strings.NewReader("hello"), ks, opts); err != nil {
+		t.Fatal(err)
+	}
+	iterAt := len(magic) + 1 + 1 + len(opts.alg) + 1 + len(opts.mode) + 1 + len(kdfPBKDF2)
+	for _, iter := range []uint32{0, minIter - 1, maxIter + 1, ^uint32(0)} {
+		file := bytes.Clone(enc.Bytes())
+		binary.BigEndian.PutUint32(file[iterAt:], iter)
+		if err := decrypt(io.Discard, bytes.NewReader(file), ks); err == nil || !strings.Contains(err.Error(), "iteration count") {
+			t.Errorf("Iteration count %d gave %v", iter, err)
+		}
+	}
+
+	h := &header{alg: opts.alg, mode: opts.mode, kdf: kdfPBKDF2, iter: minIter, salt: []byte("short"), sectorSize: 256, nonce: make([]byte, eaxNonceSize)}
+	if err := decrypt(io.Discard, bytes.NewReader(h.marshal()), ks); err == nil || !strings.Contains(err.Error(), "salt") {
+		t.Errorf("Short salt gave %v", err)
+	}
+}
+
+func TestSameInputAndOutput(t *testing.T) {
+	in := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(in, []byte("hello"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	link := in + ".link"
+	if err := os.Symlink(in, link); err != nil {
+		link = in
+	}
+	key := strings.Repeat("00", 32)
+	if err := run("enc", []string{"-key", key, "-in", in, "-out", link}, true); err == nil {
+		t.Errorf("Encrypted a file onto itself")
+	}
+	if b, _ := os.ReadFile(in); string(b) != "hello" {
+		t.Errorf("Input was overwritten: %q", b)
+	}
+}
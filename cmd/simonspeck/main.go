@@ -0,0 +1,156 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Command simonspeck encrypts and decrypts files with any Simon or
+// Speck variant in an authenticated mode.
+//
+// Usage:
+//
+//	simonspeck enc [-alg Speck128/256] [-mode EAX] [-key hex | -pass password] [-in file] [-out file]
+//	simonspeck dec [-key hex | -pass password] [-in file] [-out file]
+//	simonspeck list
+//
+// Without -key or -pass, the password is taken from the
+// SIMONSPECK_PASSWORD environment variable. Passwords are stretched
+// with PBKDF2-SHA256. Input and output default to stdin and stdout.
+// The algorithm, mode and key derivation parameters are recorded in
+// the file header, so dec needs only the key or password.
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pedroalbanese/simonspeck"
+)
+
+const passwordEnv = "SIMONSPECK_PASSWORD"
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage:\n")
+	fmt.Fprintf(os.Stderr, "  simonspeck enc [flags]  encrypt a file\n")
+	fmt.Fprintf(os.Stderr, "  simonspeck dec [flags]  decrypt a file\n")
+	fmt.Fprintf(os.Stderr, "  simonspeck list         list the available algorithms\n")
+	fmt.Fprintf(os.Stderr, "run 'simonspeck enc -h' or 'simonspeck dec -h' for the flags\n")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "enc":
+		err = run(os.Args[1], os.Args[2:], true)
+	case "dec":
+		err = run(os.Args[1], os.Args[2:], false)
+	case "list":
+		for _, v := range simonspeck.Variants() {
+			modes := "EAX"
+			if v.BlockSize == 16 {
+				modes += ", GCM"
+			}
+			fmt.Printf("%-13s %3d-bit block  %3d-bit key  %s\n", v.Name, 8*v.BlockSize, 8*v.KeySize, modes)
+		}
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "simonspeck %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func run(cmd string, args []string, enc bool) error {
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	keyHex := fs.String("key", "", "raw key in hex")
+	pass := fs.String("pass", "", "password (prefer $"+passwordEnv+")")
+	in := fs.String("in", "", "input file (default stdin)")
+	out := fs.String("out", "", "output file (default stdout)")
+	var opts encryptOptions
+	if enc {
+		fs.StringVar(&opts.alg, "alg", "Speck128/256", "algorithm; see 'simonspeck list'")
+		fs.StringVar(&opts.mode, "mode", "EAX", "authenticated mode: EAX or GCM")
+		fs.IntVar(&opts.iter, "iter", 600000, "PBKDF2 iterations")
+		fs.IntVar(&opts.sectorSize, "sector", defaultSectorSize, "plaintext bytes per sector")
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	var ks keySource
+	switch {
+	case *keyHex != "" && *pass != "":
+		return errors.New("-key and -pass are mutually exclusive")
+	case *keyHex != "":
+		key, err := hex.DecodeString(*keyHex)
+		if err != nil {
+			return fmt.Errorf("invalid -key: %v", err)
+		}
+		ks.key = key
+	case *pass != "":
+		ks.password = *pass
+	default:
+		ks.password = os.Getenv(passwordEnv)
+		if ks.password == "" {
+			return errors.New("no key given; use -key, -pass or $" + passwordEnv)
+		}
+	}
+	if enc && ks.key == nil && (opts.iter < minIter || opts.iter > maxIter) {
+		return fmt.Errorf("-iter must be between %d and %d", minIter, maxIter)
+	}
+
+	var r io.Reader = os.Stdin
+	if *in != "" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+		// Creating the output would truncate the input before it is read.
+		if *out != "" {
+			inInfo, err := f.Stat()
+			if err != nil {
+				return err
+			}
+			if outInfo, err := os.Stat(*out); err == nil && os.SameFile(inInfo, outInfo) {
+				return errors.New("-in and -out are the same file")
+			}
+		}
+	}
+	var w io.Writer = os.Stdout
+	var outFile *os.File
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		outFile = f
+		w = f
+	}
+
+	var err error
+	if enc {
+		err = encrypt(w, r, ks, opts)
+	} else {
+		err = decrypt(w, r, ks)
+	}
+	if outFile != nil {
+		if cerr := outFile.Close(); err == nil {
+			err = cerr
+		}
+		// Don't leave partial or unauthenticated output behind.
+		if err != nil {
+			os.Remove(*out)
+		}
+	}
+	return err
+}
@@ -0,0 +1,254 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package simonspeck
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// CookieMaxLength is the longest encoded cookie value that Encode
+// produces; browsers drop larger cookies.
+const CookieMaxLength = 4000
+
+var (
+	// ErrCookieInvalid is returned for a value that fails to decode or authenticate.
+	ErrCookieInvalid = errors.New("simonspeck: invalid cookie")
+	// ErrCookieExpired is returned for an authentic value past its expiry time.
+	ErrCookieExpired = errors.New("simonspeck: cookie expired")
+	// ErrCookieTooLong is returned when an encoded value exceeds CookieMaxLength.
+	ErrCookieTooLong = errors.New("simonspeck: cookie too long")
+)
+
+// CookieMode selects the AEAD that protects cookie values.
+type CookieMode int
+
+const (
+	// CookieSIV uses NewSIV with a random 16-byte nonce. It stays
+	// secure if the random source ever repeats.
+	CookieSIV CookieMode = iota
+	// CookieDeterministicSIV uses NewSIV without a nonce, so equal
+	// values with equal expiry times give equal cookies. This saves 16
+	// bytes, and lets the server recognize a value, at the cost of
+	// letting observers do the same.
+	CookieDeterministicSIV
+	// CookieGCM uses GCM with a random 12-byte nonce. Use it only with
+	// a good random source, and rotate keys long before 2^32 cookies.
+	CookieGCM
+)
+
+// CookieKey is one key of a CookieCodec. Secret must have at least 16
+// bytes; the AEAD keys are derived from it with CounterKDF.
+type CookieKey struct {
+	ID     byte
+	Secret []byte
+}
+
+// CookieCodec encrypts and authenticates cookie values, with a 128-bit
+// cipher (Speck128 or Simon128). An encoded value is the unpadded
+// base64url encoding of
+//
+//	key ID || nonce || AEAD(expiry || value)
+//
+// with the cookie name as additional data, so a value cannot be moved
+// to another cookie, and the expiry time in Unix seconds inside the
+// ciphertext, so it cannot be extended. Keys can be rotated by putting
+// a new key first: the first key encodes, and all of them decode.
+type CookieCodec struct {
+	mode  CookieMode
+	keys  []cookieKey
+	ids   map[byte]int
+	nonce int
+}
+
+type cookieKey struct {
+	id   byte
+	aead cipher.AEAD
+}
+
+// NewCookieCodec creates and returns a new CookieCodec. The variant
+// must have a 128-bit block, and there must be at least one key, with
+// distinct IDs.
+func NewCookieCodec(v Variant, mode CookieMode, keys ...CookieKey) *CookieCodec {
+	if v.BlockSize != 16 {
+		panic("NewCookieCodec() requires a 128-bit block cipher")
+	}
+	if len(keys) == 0 {
+		panic("NewCookieCodec() requires at least one key")
+	}
+	c := &CookieCodec{mode: mode, ids: make(map[byte]int)}
+	switch mode {
+	case CookieSIV:
+		c.nonce = 16
+	case CookieDeterministicSIV:
+	case CookieGCM:
+		c.nonce = 12
+	default:
+		panic("NewCookieCodec() requires a known CookieMode")
+	}
+	for _, k := range keys {
+		if len(k.Secret) < 16 {
+			panic("NewCookieCodec() requires secrets of at least 16 bytes")
+		}
+		if _, dup := c.ids[k.ID]; dup {
+			panic("NewCookieCodec() requires distinct key IDs")
+		}
+		c.ids[k.ID] = len(c.keys)
+		c.keys = append(c.keys, cookieKey{id: k.ID, aead: c.newAEAD(v, k)})
+	}
+	return c
+}
+
+func (c *CookieCodec) newAEAD(v Variant, k CookieKey) cipher.AEAD {
+	prf := CMACPRF(v)(k.Secret)
+	if c.mode == CookieGCM {
+		aead, err := cipher.NewGCM(v.New(CounterKDF(prf, []byte("simonspeck cookie GCM"), nil, v.KeySize)))
+		if err != nil {
+			panic(err)
+		}
+		return aead
+	}
+	key := CounterKDF(prf, []byte("simonspeck cookie SIV"), nil, 2*v.KeySize)
+	return NewSIV(v.New(key[:v.KeySize]), v.New(key[v.KeySize:]))
+}
+
+// Encode returns the encoded value for the cookie name, valid until
+// expires.
+func (c *CookieCodec) Encode(name string, value []byte, expires time.Time) (string, error) {
+	k := c.keys[0]
+	b := make([]byte, 1+c.nonce, 1+c.nonce+8+len(value)+k.aead.Overhead())
+	b[0] = k.id
+	nonce := b[1:]
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	if c.nonce == 0 {
+		nonce = nil
+	}
+	pt := binary.BigEndian.AppendUint64(nil, uint64(expires.Unix()))
+	pt = append(pt, value...)
+	b = k.aead.Seal(b, nonce, pt, []byte(name))
+	if base64.RawURLEncoding.EncodedLen(len(b)) > CookieMaxLength {
+		return "", ErrCookieTooLong
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Decode returns the value and expiry time of an encoded value for the
+// cookie name. It returns ErrCookieExpired if the value has expired,
+// and ErrCookieInvalid if it was not produced by Encode for this name
+// under one of the codec's keys.
+func (c *CookieCodec) Decode(name, encoded string) ([]byte, time.Time, error) {
+	value, expires, _, err := c.decode(name, encoded)
+	return value, expires, err
+}
+
+// decode is Decode, also reporting whether the value was encoded with
+// a key other than the first.
+func (c *CookieCodec) decode(name, encoded string) ([]byte, time.Time, bool, error) {
+	if len(encoded) > CookieMaxLength {
+		return nil, time.Time{}, false, ErrCookieInvalid
+	}
+	b, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(b) < 1+c.nonce {
+		return nil, time.Time{}, false, ErrCookieInvalid
+	}
+	i, ok := c.ids[b[0]]
+	if !ok {
+		return nil, time.Time{}, false, ErrCookieInvalid
+	}
+	var nonce []byte
+	if c.nonce > 0 {
+		nonce = b[1 : 1+c.nonce]
+	}
+	pt, err := c.keys[i].aead.Open(nil, nonce, b[1+c.nonce:], []byte(name))
+	if err != nil || len(pt) < 8 {
+		return nil, time.Time{}, false, ErrCookieInvalid
+	}
+	expires := time.Unix(int64(binary.BigEndian.Uint64(pt)), 0)
+	if !time.Now().Before(expires) {
+		return nil, time.Time{}, false, ErrCookieExpired
+	}
+	return pt[8:], expires, i != 0, nil
+}
+
+// Cookie returns a cookie named name holding value for maxAge. It is
+// HttpOnly, Secure and SameSite=Lax, with path "/"; adjust these
+// fields as needed before calling http.SetCookie.
+func (c *CookieCodec) Cookie(name string, value []byte, maxAge time.Duration) (*http.Cookie, error) {
+	return c.cookie(name, value, time.Now().Add(maxAge))
+}
+
+func (c *CookieCodec) cookie(name string, value []byte, expires time.Time) (*http.Cookie, error) {
+	encoded, err := c.Encode(name, value, expires)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Cookie{
+		Name:     name,
+		Value:    encoded,
+		Path:     "/",
+		Expires:  expires,
+		MaxAge:   int(time.Until(expires) / time.Second),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	}, nil
+}
+
+// Value returns the decoded value of the cookie name of r. It returns
+// http.ErrNoCookie if there is no such cookie.
+func (c *CookieCodec) Value(r *http.Request, name string) ([]byte, error) {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return nil, err
+	}
+	value, _, err := c.Decode(name, cookie.Value)
+	return value, err
+}
+
+type cookieContextKey string
+
+// Middleware returns a handler that decodes the cookie name and makes
+// its value available to next through CookieValue. Invalid and expired
+// cookies are deleted. A cookie encoded under an older key is encoded
+// again under the current one, with the same expiry time, so rotating
+// keys needs nothing more than putting the new key first and, once
+// the old cookies have expired, dropping the old key.
+func (c *CookieCodec) Middleware(name string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(name)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		value, expires, old, err := c.decode(name, cookie.Value)
+		if err != nil {
+			http.SetCookie(w, &http.Cookie{Name: name, Path: "/", MaxAge: -1})
+			next.ServeHTTP(w, r)
+			return
+		}
+		if old {
+			if fresh, err := c.cookie(name, value, expires); err == nil {
+				http.SetCookie(w, fresh)
+			}
+		}
+		ctx := context.WithValue(r.Context(), cookieContextKey(name), value)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// CookieValue returns the value of the cookie name decoded by
+// Middleware, and whether there was a valid one.
+func CookieValue(ctx context.Context, name string) ([]byte, bool) {
+	value, ok := ctx.Value(cookieContextKey(name)).([]byte)
+	return value, ok
+}
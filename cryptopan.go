@@ -0,0 +1,152 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package simonspeck
+
+import (
+	"crypto/cipher"
+	"net/netip"
+	"runtime"
+	"sync"
+)
+
+// CryptoPAn implements the prefix-preserving address anonymization
+// scheme of Xu, Fan, Ammar and Moon [1] with a 128-bit block cipher
+// (Speck128 or Simon128) as the pseudorandom function in place of
+// AES. If two addresses share a k-bit prefix, their anonymized forms
+// share a k-bit prefix too. A CryptoPAn is safe for concurrent use.
+//
+// [1]: https://doi.org/10.1016/j.comnet.2004.03.033
+type CryptoPAn struct {
+	block cipher.Block
+	pad   [16]byte
+}
+
+// NewCryptoPAn creates and returns a new CryptoPAn. The block cipher
+// must have a 128-bit block length, and padKey is the 128-bit secret
+// that, encrypted under block, becomes the padding for each PRF input;
+// this is the second half of the 256-bit key in the original scheme.
+// For example:
+//
+//	pan := NewCryptoPAn(NewSpeck128(key[:16]), key[16:32])
+func NewCryptoPAn(block cipher.Block, padKey []byte) *CryptoPAn {
+	if block.BlockSize() != 16 {
+		panic("NewCryptoPAn() requires a 128-bit block cipher")
+	}
+	if len(padKey) != 16 {
+		panic("NewCryptoPAn() requires a 128-bit pad key")
+	}
+	c := &CryptoPAn{block: block}
+	block.Encrypt(c.pad[:], padKey)
+	return c
+}
+
+// Anonymize returns the prefix-preserving anonymization of addr. IPv4
+// addresses are anonymized over 32 bits and everything else, including
+// IPv4-mapped IPv6 addresses, over 128 bits.
+func (c *CryptoPAn) Anonymize(addr netip.Addr) (netip.Addr, error) {
+	return c.transform(addr, false)
+}
+
+// Deanonymize reverses Anonymize. Only holders of the key can do
+// this.
+func (c *CryptoPAn) Deanonymize(addr netip.Addr) (netip.Addr, error) {
+	return c.transform(addr, true)
+}
+
+// AnonymizeBatch anonymizes src into dst, which must be at least as
+// long as src; dst and src may be the same slice. Large batches are
+// spread across GOMAXPROCS goroutines. On error, the first failing
+// address is reported and the contents of dst are unspecified.
+func (c *CryptoPAn) AnonymizeBatch(dst, src []netip.Addr) error {
+	return c.batch(dst, src, false)
+}
+
+// DeanonymizeBatch is the batch counterpart of Deanonymize.
+func (c *CryptoPAn) DeanonymizeBatch(dst, src []netip.Addr) error {
+	return c.batch(dst, src, true)
+}
+
+// Batches smaller than this are processed on the calling goroutine.
+const cryptoPAnParallelThreshold = 1024
+
+func (c *CryptoPAn) batch(dst, src []netip.Addr, reverse bool) error {
+	dst = dst[:len(src)]
+	workers := runtime.GOMAXPROCS(0)
+	if len(src) < cryptoPAnParallelThreshold || workers == 1 {
+		return c.batchRange(dst, src, reverse)
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, workers)
+	chunk := (len(src) + workers - 1) / workers
+	for w := 0; w < workers; w++ {
+		lo, hi := w*chunk, (w+1)*chunk
+		if lo >= len(src) {
+			break
+		}
+		if hi > len(src) {
+			hi = len(src)
+		}
+		wg.Add(1)
+		go func(w, lo, hi int) {
+			defer wg.Done()
+			errs[w] = c.batchRange(dst[lo:hi], src[lo:hi], reverse)
+		}(w, lo, hi)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *CryptoPAn) batchRange(dst, src []netip.Addr, reverse bool) error {
+	for i, addr := range src {
+		out, err := c.transform(addr, reverse)
+		if err != nil {
+			return err
+		}
+		dst[i] = out
+	}
+	return nil
+}
+
+func (c *CryptoPAn) transform(addr netip.Addr, reverse bool) (netip.Addr, error) {
+	if !addr.IsValid() {
+		return netip.Addr{}, ErrInvalidAddr
+	}
+	if addr.Is4() {
+		a := addr.As4()
+		c.permute(a[:], reverse)
+		return netip.AddrFrom4(a), nil
+	}
+	a := addr.As16()
+	c.permute(a[:], reverse)
+	return netip.AddrFrom16(a).WithZone(addr.Zone()), nil
+}
+
+// permute anonymizes (or, if reverse is set, deanonymizes) the
+// big-endian bit string b in place. Bit i of the output is bit i of
+// the original address XOR the most significant bit of E(in), where in
+// is the first i original bits followed by the remaining pad bits.
+// When deanonymizing, the original bits are recovered one at a time,
+// so they are always known before they are needed.
+func (c *CryptoPAn) permute(b []byte, reverse bool) {
+	var in, out [16]byte
+	in = c.pad
+	for i := 0; i < 8*len(b); i++ {
+		c.block.Encrypt(out[:], in[:])
+		flip := out[0] >> 7
+		mask := byte(0x80) >> uint(i%8)
+		bit := (b[i/8] >> uint(7-i%8)) & 1
+		b[i/8] ^= flip << uint(7-i%8)
+		if reverse {
+			bit ^= flip
+		}
+		// Fix bit i of the PRF input to the original address bit.
+		in[i/8] = in[i/8]&^mask | bit<<uint(7-i%8)
+	}
+}
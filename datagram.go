@@ -0,0 +1,296 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package simonspeck
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"net"
+	"sync"
+	"time"
+)
+
+// DatagramReplayWindow is the number of packet numbers, counting the
+// highest one seen, that a DatagramConn tracks so that it can accept
+// each of them once, to allow for reordering in the network.
+const DatagramReplayWindow = 64
+
+// DatagramEpochLookahead is the number of epochs beyond its current
+// one from which a DatagramConn accepts packets, so that it follows a
+// sender whose packets of whole epochs were lost.
+const DatagramEpochLookahead = 4
+
+// DatagramConfig configures a DatagramConn. Both ends must use the
+// same configuration.
+type DatagramConfig struct {
+	// Key is the shared key, of at least 16 bytes.
+	Key []byte
+	// Variant is the cipher used to seal packets: GCM for the 128-bit
+	// ciphers and EAX, with a tag of one block, for the others. It
+	// defaults to Speck128/128.
+	Variant Variant
+	// PacketNumberSize is the length of the packet number in the
+	// header, 4 (the default) or 6 bytes.
+	PacketNumberSize int
+	// RekeyAfter is the number of packets sent under one key, which
+	// must fit in the packet number. It defaults to 2^20 for ciphers
+	// with a 64-bit block or more, 2^16 for 48-bit blocks and 2^8 for
+	// 32-bit blocks. With payloads of up to 64 bytes, that keeps the
+	// block cipher calls under one key, CTR and CMAC together, below
+	// 2^(n/2-2) for an n-bit block, a quarter of the birthday bound;
+	// lower RekeyAfter in proportion for larger payloads, e.g. to 2^5
+	// for 512-byte payloads with a 32-bit block. Rekeying does not
+	// help against forgery: with a 32-bit block the tag is only 4
+	// bytes, so each forged packet is accepted with probability 2^-32.
+	RekeyAfter uint64
+}
+
+// DatagramConn is a net.PacketConn that protects the packets
+// exchanged with one peer holding the same key. Each packet is
+//
+//	key ID || packet number || sealed payload
+//
+// with the header as additional data. Keys are numbered by a 64-bit
+// epoch, of which the key ID is the low byte: the sender moves to the
+// next epoch every RekeyAfter packets, and restarts packet numbers at
+// zero. Each key is derived from Key with CounterKDF, using CMAC over
+// Variant, from the direction and the epoch, so no key and nonce pair
+// is ever used twice.
+//
+// The receiver accepts packets of its current epoch, of the
+// DatagramEpochLookahead epochs after it and, for stragglers, of the
+// previous one. The first authentic packet of a later epoch makes it
+// current; a forged one changes nothing. If more than
+// DatagramEpochLookahead*RekeyAfter packets in a row are lost, the
+// receiver can no longer follow the sender, and both ends must start
+// over with new DatagramConns. Within an epoch, a packet number is
+// accepted once, and only if it is less than DatagramReplayWindow
+// below the highest seen so far. ReadFrom silently drops packets that
+// are forged, replayed, too old, or of any other epoch, as a UDP
+// socket drops corrupted ones.
+//
+// A DatagramConn accepts packets from any address, since the key
+// authenticates them; use one DatagramConn, and one socket, per peer.
+type DatagramConn struct {
+	pc     net.PacketConn
+	config DatagramConfig
+
+	sendLabel, recvLabel string
+
+	sendMu    sync.Mutex
+	sendEpoch uint64
+	sendPN    uint64
+	sendAEAD  cipher.AEAD
+
+	recvMu    sync.Mutex
+	cur, prev *datagramEpoch
+	ahead     [DatagramEpochLookahead]*datagramEpoch // epochs cur+1, cur+2, ...
+	recvBuf   []byte
+
+	headerSize int
+	overhead   int
+}
+
+// datagramEpoch is the receive state for one key.
+type datagramEpoch struct {
+	epoch uint64
+	aead  cipher.AEAD
+	top   uint64 // highest packet number seen
+	seen  uint64 // bit i is set if top-i has been seen
+	any   bool
+}
+
+// DatagramClient returns the client side of a DatagramConn over pc.
+func DatagramClient(pc net.PacketConn, config *DatagramConfig) *DatagramConn {
+	return newDatagramConn(pc, config, "client", "server")
+}
+
+// DatagramServer returns the server side of a DatagramConn over pc.
+func DatagramServer(pc net.PacketConn, config *DatagramConfig) *DatagramConn {
+	return newDatagramConn(pc, config, "server", "client")
+}
+
+func newDatagramConn(pc net.PacketConn, config *DatagramConfig, send, recv string) *DatagramConn {
+	if len(config.Key) < 16 {
+		panic("simonspeck: datagram key must be at least 16 bytes")
+	}
+	c := &DatagramConn{pc: pc, config: *config, sendLabel: send, recvLabel: recv}
+	c.config.Key = append([]byte(nil), config.Key...)
+	if c.config.Variant.New == nil {
+		c.config.Variant, _ = LookupVariant("Speck128/128")
+	}
+	switch c.config.PacketNumberSize {
+	case 0:
+		c.config.PacketNumberSize = 4
+	case 4, 6:
+	default:
+		panic("simonspeck: datagram packet number size must be 4 or 6")
+	}
+	if c.config.RekeyAfter == 0 {
+		switch c.config.Variant.BlockSize {
+		case 4:
+			c.config.RekeyAfter = 1 << 8
+		case 6:
+			c.config.RekeyAfter = 1 << 16
+		default:
+			c.config.RekeyAfter = 1 << 20
+		}
+	}
+	if c.config.RekeyAfter-1 > 1<<(8*uint(c.config.PacketNumberSize))-1 {
+		panic("simonspeck: datagram RekeyAfter exceeds the packet number space")
+	}
+	c.headerSize = 1 + c.config.PacketNumberSize
+	c.sendAEAD = c.newAEAD(c.sendLabel, 0)
+	c.cur = &datagramEpoch{aead: c.newAEAD(c.recvLabel, 0)}
+	c.overhead = c.headerSize + c.sendAEAD.Overhead()
+	c.recvBuf = make([]byte, 1<<16)
+	return c
+}
+
+// Overhead returns the number of bytes added to each packet.
+func (c *DatagramConn) Overhead() int { return c.overhead }
+
+func (c *DatagramConn) newAEAD(direction string, epoch uint64) cipher.AEAD {
+	v := c.config.Variant
+	context := binary.BigEndian.AppendUint64([]byte(direction), epoch)
+	key := CounterKDF(CMACPRF(v)(c.config.Key), []byte("simonspeck datagram"), context, v.KeySize)
+	block := v.New(key)
+	if v.BlockSize == 16 {
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			panic(err)
+		}
+		return aead
+	}
+	return NewEAXWithNonceSize(block, 8)
+}
+
+func datagramNonce(aead cipher.AEAD, pn uint64) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], pn)
+	return nonce
+}
+
+// WriteTo seals p into one packet and sends it to addr.
+func (c *DatagramConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	c.sendMu.Lock()
+	if c.sendPN == c.config.RekeyAfter {
+		c.sendEpoch++
+		c.sendPN = 0
+		c.sendAEAD = c.newAEAD(c.sendLabel, c.sendEpoch)
+	}
+	pkt := make([]byte, c.headerSize, c.overhead+len(p))
+	pkt[0] = byte(c.sendEpoch)
+	var pn [8]byte
+	binary.BigEndian.PutUint64(pn[:], c.sendPN)
+	copy(pkt[1:], pn[8-c.config.PacketNumberSize:])
+	pkt = c.sendAEAD.Seal(pkt, datagramNonce(c.sendAEAD, c.sendPN), p, pkt)
+	c.sendPN++
+	c.sendMu.Unlock()
+
+	if _, err := c.pc.WriteTo(pkt, addr); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// ReadFrom reads the next authentic packet into p, dropping any others.
+// As with a UDP socket, a payload longer than p is truncated.
+func (c *DatagramConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	c.recvMu.Lock()
+	defer c.recvMu.Unlock()
+	for {
+		n, addr, err := c.pc.ReadFrom(c.recvBuf)
+		if err != nil {
+			return 0, addr, err
+		}
+		if payload, ok := c.open(c.recvBuf[:n]); ok {
+			return copy(p, payload), addr, nil
+		}
+	}
+}
+
+// open authenticates and decrypts pkt in place, and updates the
+// receive state.
+func (c *DatagramConn) open(pkt []byte) ([]byte, bool) {
+	if len(pkt) < c.overhead {
+		return nil, false
+	}
+	var pnBytes [8]byte
+	copy(pnBytes[8-c.config.PacketNumberSize:], pkt[1:c.headerSize])
+	pn := binary.BigEndian.Uint64(pnBytes[:])
+
+	e := c.cur
+	keyID := pkt[0]
+	switch d := keyID - byte(c.cur.epoch); {
+	case d == 0:
+	case d <= DatagramEpochLookahead:
+		// Keys of later epochs are derived on demand and cached, but
+		// only become current once a packet authenticates under them.
+		if c.ahead[d-1] == nil {
+			epoch := c.cur.epoch + uint64(d)
+			c.ahead[d-1] = &datagramEpoch{epoch: epoch, aead: c.newAEAD(c.recvLabel, epoch)}
+		}
+		e = c.ahead[d-1]
+	case c.prev != nil && keyID == byte(c.prev.epoch):
+		e = c.prev
+	default:
+		return nil, false
+	}
+	if !e.check(pn) {
+		return nil, false
+	}
+	hdr, body := pkt[:c.headerSize], pkt[c.headerSize:]
+	payload, err := e.aead.Open(body[:0], datagramNonce(e.aead, pn), body, hdr)
+	if err != nil {
+		return nil, false
+	}
+	e.mark(pn)
+	if e.epoch > c.cur.epoch {
+		d := e.epoch - c.cur.epoch
+		c.prev, c.cur = c.cur, e
+		n := copy(c.ahead[:], c.ahead[d:])
+		clear(c.ahead[n:])
+	}
+	return payload, true
+}
+
+// check reports whether pn is new and within the replay window.
+func (e *datagramEpoch) check(pn uint64) bool {
+	switch {
+	case !e.any || pn > e.top:
+		return true
+	case e.top-pn >= DatagramReplayWindow:
+		return false
+	default:
+		return e.seen&(1<<(e.top-pn)) == 0
+	}
+}
+
+// mark records pn as seen, sliding the window if it is the new top.
+func (e *datagramEpoch) mark(pn uint64) {
+	if !e.any {
+		e.top, e.seen, e.any = pn, 1, true
+		return
+	}
+	if pn > e.top {
+		shift := pn - e.top
+		if shift >= DatagramReplayWindow {
+			e.seen = 0
+		} else {
+			e.seen <<= shift
+		}
+		e.top = pn
+		e.seen |= 1
+		return
+	}
+	e.seen |= 1 << (e.top - pn)
+}
+
+func (c *DatagramConn) Close() error                       { return c.pc.Close() }
+func (c *DatagramConn) LocalAddr() net.Addr                { return c.pc.LocalAddr() }
+func (c *DatagramConn) SetDeadline(t time.Time) error      { return c.pc.SetDeadline(t) }
+func (c *DatagramConn) SetReadDeadline(t time.Time) error  { return c.pc.SetReadDeadline(t) }
+func (c *DatagramConn) SetWriteDeadline(t time.Time) error { return c.pc.SetWriteDeadline(t) }
@@ -0,0 +1,288 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package simonspeck
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+)
+
+const (
+	drbgBlockSize      = 16
+	drbgReseedInterval = 1 << 48
+	// DRBGMaxRequest is the most bytes a single Generate call may
+	// return, from the 2^19-bit limit of SP 800-90A.
+	DRBGMaxRequest = 1 << 16
+)
+
+var (
+	// ErrDRBGRequestTooLarge is returned for requests over DRBGMaxRequest bytes.
+	ErrDRBGRequestTooLarge = errors.New("simonspeck: DRBG request too large")
+	// ErrDRBGInputTooLong is returned for input longer than the seed length.
+	ErrDRBGInputTooLong = errors.New("simonspeck: DRBG input too long")
+)
+
+// CTRDRBGOptions configures a CTRDRBG. The zero value is a DRBG with
+// a derivation function, without prediction resistance, seeded from
+// crypto/rand.
+type CTRDRBGOptions struct {
+	// Entropy is the entropy source. It defaults to crypto/rand.Reader.
+	Entropy io.Reader
+	// NoDerivationFunction selects CTR_DRBG without a derivation
+	// function. The entropy source must then deliver full-entropy
+	// input, and personalization strings and additional inputs are
+	// limited to the seed length.
+	NoDerivationFunction bool
+	// PredictionResistance reseeds before every request.
+	PredictionResistance bool
+	// Personalization is an optional personalization string.
+	Personalization []byte
+	// Nonce is the instantiation nonce used with the derivation
+	// function. If nil, it is read from Entropy.
+	Nonce []byte
+}
+
+// CTRDRBG is the CTR_DRBG deterministic random bit generator of NIST
+// SP 800-90A with Speck128 or Simon128 in place of AES. Its Read
+// method makes it usable wherever an io.Reader of random bytes is
+// expected. A CTRDRBG is safe for concurrent use.
+type CTRDRBG struct {
+	mu      sync.Mutex
+	v       Variant
+	entropy io.Reader
+	df      bool
+	pr      bool
+	block   cipher.Block
+	counter [drbgBlockSize]byte // V
+	reseeds uint64
+}
+
+// NewCTRDRBG instantiates a CTR_DRBG over the given variant, which
+// must have a 128-bit block. The key size of the variant sets the
+// security strength.
+func NewCTRDRBG(v Variant, opts *CTRDRBGOptions) (*CTRDRBG, error) {
+	if v.BlockSize != drbgBlockSize {
+		panic("NewCTRDRBG() requires a 128-bit block variant")
+	}
+	if opts == nil {
+		opts = new(CTRDRBGOptions)
+	}
+	d := &CTRDRBG{
+		v:       v,
+		entropy: opts.Entropy,
+		df:      !opts.NoDerivationFunction,
+		pr:      opts.PredictionResistance,
+	}
+	if d.entropy == nil {
+		d.entropy = rand.Reader
+	}
+	entropy, err := d.getEntropy()
+	if err != nil {
+		return nil, err
+	}
+	var seed []byte
+	if d.df {
+		nonce := opts.Nonce
+		if nonce == nil {
+			nonce = make([]byte, v.KeySize/2)
+			if _, err := io.ReadFull(d.entropy, nonce); err != nil {
+				return nil, err
+			}
+		}
+		seed = d.derive(entropy, nonce, opts.Personalization)
+	} else {
+		if seed, err = d.padded(opts.Personalization); err != nil {
+			return nil, err
+		}
+		xorBytes(seed, seed, entropy)
+	}
+	d.block = v.New(make([]byte, v.KeySize))
+	d.update(seed)
+	d.reseeds = 1
+	return d, nil
+}
+
+func (d *CTRDRBG) seedLen() int {
+	return d.v.KeySize + drbgBlockSize
+}
+
+// getEntropy reads entropy input: the security strength in bytes
+// with a derivation function, and a full seed without one.
+func (d *CTRDRBG) getEntropy() ([]byte, error) {
+	n := d.v.KeySize
+	if !d.df {
+		n = d.seedLen()
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(d.entropy, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// padded returns input padded with zeros to the seed length, for use
+// without a derivation function.
+func (d *CTRDRBG) padded(input []byte) ([]byte, error) {
+	if len(input) > d.seedLen() {
+		return nil, ErrDRBGInputTooLong
+	}
+	b := make([]byte, d.seedLen())
+	copy(b, input)
+	return b, nil
+}
+
+// increment adds one to the big-endian counter V.
+func (d *CTRDRBG) increment() {
+	for i := drbgBlockSize - 1; i >= 0; i-- {
+		d.counter[i]++
+		if d.counter[i] != 0 {
+			break
+		}
+	}
+}
+
+// update is CTR_DRBG_Update.
+func (d *CTRDRBG) update(provided []byte) {
+	temp := make([]byte, d.seedLen()+drbgBlockSize)
+	for i := 0; i < d.seedLen(); i += drbgBlockSize {
+		d.increment()
+		d.block.Encrypt(temp[i:], d.counter[:])
+	}
+	temp = temp[:d.seedLen()]
+	xorBytes(temp, temp, provided)
+	d.block = d.v.New(temp[:d.v.KeySize])
+	copy(d.counter[:], temp[d.v.KeySize:])
+}
+
+// derive is Block_Cipher_df over the concatenation of inputs,
+// returning seedLen bytes.
+func (d *CTRDRBG) derive(inputs ...[]byte) []byte {
+	var s []byte
+	l := 0
+	for _, in := range inputs {
+		l += len(in)
+	}
+	s = binary.BigEndian.AppendUint32(s, uint32(l))
+	s = binary.BigEndian.AppendUint32(s, uint32(d.seedLen()))
+	for _, in := range inputs {
+		s = append(s, in...)
+	}
+	s = append(s, 0x80)
+	for len(s)%drbgBlockSize != 0 {
+		s = append(s, 0)
+	}
+
+	key := make([]byte, d.v.KeySize)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	k := d.v.New(key)
+	temp := make([]byte, 0, d.seedLen()+drbgBlockSize)
+	for i := uint32(0); len(temp) < d.seedLen(); i++ {
+		// BCC(K, IV || S), with IV the 32-bit counter padded to a block.
+		chain := make([]byte, drbgBlockSize)
+		iv := binary.BigEndian.AppendUint32(nil, i)
+		iv = append(iv, make([]byte, drbgBlockSize-4)...)
+		xorBytes(chain, chain, iv)
+		k.Encrypt(chain, chain)
+		for j := 0; j < len(s); j += drbgBlockSize {
+			xorBytes(chain, chain, s[j:j+drbgBlockSize])
+			k.Encrypt(chain, chain)
+		}
+		temp = append(temp, chain...)
+	}
+	k = d.v.New(temp[:d.v.KeySize])
+	x := temp[d.v.KeySize:d.seedLen()]
+	out := make([]byte, 0, d.seedLen()+drbgBlockSize)
+	for len(out) < d.seedLen() {
+		k.Encrypt(x, x)
+		out = append(out, x...)
+	}
+	return out[:d.seedLen()]
+}
+
+// Reseed mixes fresh entropy and the optional additional input into
+// the state.
+func (d *CTRDRBG) Reseed(additional []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.reseed(additional)
+}
+
+func (d *CTRDRBG) reseed(additional []byte) error {
+	entropy, err := d.getEntropy()
+	if err != nil {
+		return err
+	}
+	var seed []byte
+	if d.df {
+		seed = d.derive(entropy, additional)
+	} else {
+		if seed, err = d.padded(additional); err != nil {
+			return err
+		}
+		xorBytes(seed, seed, entropy)
+	}
+	d.update(seed)
+	d.reseeds = 1
+	return nil
+}
+
+// Generate fills out, which may be at most DRBGMaxRequest bytes long,
+// with pseudorandom bytes, mixing in the optional additional input.
+// It reseeds first if prediction resistance is enabled or the reseed
+// interval has been reached.
+func (d *CTRDRBG) Generate(out, additional []byte) error {
+	if len(out) > DRBGMaxRequest {
+		return ErrDRBGRequestTooLarge
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.pr || d.reseeds > drbgReseedInterval {
+		if err := d.reseed(additional); err != nil {
+			return err
+		}
+		additional = nil
+	}
+	var seed []byte
+	if len(additional) > 0 {
+		var err error
+		if d.df {
+			seed = d.derive(additional)
+		} else if seed, err = d.padded(additional); err != nil {
+			return err
+		}
+		d.update(seed)
+	} else {
+		seed = make([]byte, d.seedLen())
+	}
+	var block [drbgBlockSize]byte
+	for i := 0; i < len(out); i += drbgBlockSize {
+		d.increment()
+		d.block.Encrypt(block[:], d.counter[:])
+		copy(out[i:], block[:])
+	}
+	d.update(seed)
+	d.reseeds++
+	return nil
+}
+
+// Read implements io.Reader by generating len(p) bytes in requests
+// of at most DRBGMaxRequest bytes.
+func (d *CTRDRBG) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		c := min(len(p)-n, DRBGMaxRequest)
+		if err := d.Generate(p[n:n+c], nil); err != nil {
+			return n, err
+		}
+		n += c
+	}
+	return n, nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package simonspeck
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+)
+
+// ErrOpen is returned when a ciphertext fails authentication.
+var ErrOpen = errors.New("simonspeck: message authentication failed")
+
+// eax implements the EAX mode of Bellare, Rogaway and Wagner [1].
+// Unlike GCM, it only needs CTR and CMAC, so it works with every
+// block length in this package.
+//
+// [1]: https://www.cs.ucdavis.edu/~rogaway/papers/eax.pdf
+type eax struct {
+	block     cipher.Block
+	mac       *cmac
+	nonceSize int
+}
+
+// NewEAX returns the given block cipher wrapped in EAX mode, with a
+// nonce as long as a block and a full-block tag.
+func NewEAX(block cipher.Block) cipher.AEAD {
+	return NewEAXWithNonceSize(block, block.BlockSize())
+}
+
+// NewEAXWithNonceSize is like NewEAX, but accepts nonces of the given
+// length. EAX compresses the nonce with CMAC, so a nonce longer than
+// a block is useful with the small block lengths, e.g. to leave room
+// for a counter.
+func NewEAXWithNonceSize(block cipher.Block, size int) cipher.AEAD {
+	if size <= 0 {
+		panic("NewEAXWithNonceSize() requires a positive nonce size")
+	}
+	return &eax{block: block, mac: newCMAC(block), nonceSize: size}
+}
+
+func (e *eax) NonceSize() int { return e.nonceSize }
+func (e *eax) Overhead() int  { return e.block.BlockSize() }
+
+// omac computes OMAC^t(data), i.e. CMAC over the block encoding of t
+// followed by data.
+func (e *eax) omac(t byte, data []byte) []byte {
+	tweak := make([]byte, e.block.BlockSize())
+	tweak[len(tweak)-1] = t
+	m := e.mac.fresh()
+	m.Write(tweak)
+	m.Write(data)
+	return m.Sum(nil)
+}
+
+func (e *eax) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != e.nonceSize {
+		panic("simonspeck: incorrect nonce length given to EAX")
+	}
+	ret, out := sliceForAppend(dst, len(plaintext)+e.Overhead())
+	n := e.omac(0, nonce)
+	h := e.omac(1, additionalData)
+	cipher.NewCTR(e.block, n).XORKeyStream(out, plaintext)
+	c := e.omac(2, out[:len(plaintext)])
+	tag := out[len(plaintext):]
+	xorBytes(tag, n, h)
+	xorBytes(tag, tag, c)
+	return ret
+}
+
+func (e *eax) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != e.nonceSize {
+		panic("simonspeck: incorrect nonce length given to EAX")
+	}
+	if len(ciphertext) < e.Overhead() {
+		return nil, ErrOpen
+	}
+	tag := ciphertext[len(ciphertext)-e.Overhead():]
+	ciphertext = ciphertext[:len(ciphertext)-e.Overhead()]
+	n := e.omac(0, nonce)
+	h := e.omac(1, additionalData)
+	c := e.omac(2, ciphertext)
+	xorBytes(c, c, n)
+	xorBytes(c, c, h)
+	if subtle.ConstantTimeCompare(c, tag) != 1 {
+		return nil, ErrOpen
+	}
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	cipher.NewCTR(e.block, n).XORKeyStream(out, ciphertext)
+	return ret, nil
+}
+
+// sliceForAppend takes a slice and a requested number of bytes. It
+// returns a slice with the contents of the given slice followed by
+// that many bytes and a second slice that aliases into it and
+// contains only the extra bytes, as in crypto/cipher.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
@@ -0,0 +1,314 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package simonspeck
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	encFSChunkSize = 64 * 1024 // plaintext bytes per chunk
+	encFSNonceSize = 12        // GCM nonce
+	encFSOverhead  = 16        // GCM tag
+)
+
+// EncryptedFS is a read-only fs.FS that decrypts a tree of encrypted
+// files stored in another fs.FS. Every path component is encrypted
+// with SIV, bound to the encrypted path of its parent directory, and
+// encoded with unpadded URL-safe base64. Every file is a seekable
+// stream (see NewSeekableWriter) sealed with GCM under a key derived
+// from the master key and the encrypted path of the file. Directory
+// structure, file sizes (to within a chunk) and modification times
+// are not hidden. Use EncryptPath and NewFileWriter to build a tree.
+type EncryptedFS struct {
+	base   fs.FS
+	v      Variant
+	master cipher.Block
+	names  *SIV
+}
+
+// NewEncryptedFS returns an EncryptedFS over base. The variant must
+// have a 128-bit block (Speck128 or Simon128), and masterKey must be
+// a key for it.
+func NewEncryptedFS(base fs.FS, v Variant, masterKey []byte) *EncryptedFS {
+	if v.BlockSize != 16 {
+		panic("NewEncryptedFS() requires a 128-bit block variant")
+	}
+	if len(masterKey) != v.KeySize {
+		panic("NewEncryptedFS() requires a master key of the variant's key size")
+	}
+	e := &EncryptedFS{base: base, v: v, master: v.New(masterKey)}
+	e.names = NewSIV(v.New(e.deriveKey("name mac", nil)), v.New(e.deriveKey("name ctr", nil)))
+	return e
+}
+
+func (e *EncryptedFS) deriveKey(label string, context []byte) []byte {
+	return CounterKDF(NewCMAC(e.master), []byte(label), context, e.v.KeySize)
+}
+
+func (e *EncryptedFS) fileAEAD(encPath string) cipher.AEAD {
+	aead, err := cipher.NewGCM(e.v.New(e.deriveKey("file", []byte(encPath))))
+	if err != nil {
+		panic(err)
+	}
+	return aead
+}
+
+// EncryptPath returns the encrypted form of the slash-separated path
+// name, as it is stored in the underlying file system.
+func (e *EncryptedFS) EncryptPath(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "encrypt", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return ".", nil
+	}
+	enc := "."
+	for _, elem := range strings.Split(name, "/") {
+		sealed := e.names.Seal(nil, nil, []byte(elem), []byte(enc))
+		enc = path.Join(enc, base64.RawURLEncoding.EncodeToString(sealed))
+	}
+	return enc, nil
+}
+
+// decryptName decrypts a single encrypted path component found in
+// the directory with encrypted path encDir.
+func (e *EncryptedFS) decryptName(encDir, encName string) (string, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(encName)
+	if err != nil {
+		return "", ErrOpen
+	}
+	name, err := e.names.Open(nil, nil, sealed, []byte(encDir))
+	if err != nil {
+		return "", err
+	}
+	return string(name), nil
+}
+
+// NewFileWriter returns an io.WriteCloser that encrypts the contents
+// of the file name into w, which should be stored in the underlying
+// file system under EncryptPath(name). Close does not close w.
+func (e *EncryptedFS) NewFileWriter(w io.Writer, name string) (io.WriteCloser, error) {
+	encPath, err := e.EncryptPath(name)
+	if err != nil {
+		return nil, err
+	}
+	aead := e.fileAEAD(encPath)
+	prefix := make([]byte, aead.NonceSize()-streamNonceOverhead)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	return NewSeekableWriter(w, aead, prefix, encFSChunkSize)
+}
+
+// plainSize returns the plaintext size of an encrypted file of the
+// given size, or -1 if it is too short to be valid.
+func (e *EncryptedFS) plainSize(size int64) int64 {
+	overhead := int64(encFSOverhead)
+	body := size - int64(len(seekableHeader(make([]byte, encFSNonceSize-streamNonceOverhead), encFSChunkSize)))
+	if body < overhead {
+		return -1
+	}
+	chunks := (body + encFSChunkSize + overhead - 1) / (encFSChunkSize + overhead)
+	return body - chunks*overhead
+}
+
+// Open implements fs.FS.
+func (e *EncryptedFS) Open(name string) (fs.File, error) {
+	encPath, err := e.EncryptPath(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	f, err := e.base.Open(encPath)
+	if err != nil {
+		if pe, ok := err.(*fs.PathError); ok {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: pe.Err}
+		}
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	fi := &encFileInfo{name: path.Base(name), info: info, size: info.Size()}
+	if info.IsDir() {
+		return &encDir{fs: e, f: f, name: name, encPath: encPath, info: fi}, nil
+	}
+
+	ra, ok := f.(io.ReaderAt)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			f.Close()
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		ra = bytes.NewReader(data)
+	}
+	sr, err := NewSeekableReader(ra, info.Size(), e.fileAEAD(encPath))
+	if err != nil {
+		f.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	fi.size = sr.Size()
+	cache := &encChunkCache{sr: sr, idx: -1}
+	return &encFile{f: f, sr: sr, SectionReader: io.NewSectionReader(cache, 0, sr.Size()), info: fi}, nil
+}
+
+type encFileInfo struct {
+	name string
+	info fs.FileInfo
+	size int64
+}
+
+func (fi *encFileInfo) Name() string       { return fi.name }
+func (fi *encFileInfo) Size() int64        { return fi.size }
+func (fi *encFileInfo) Mode() fs.FileMode  { return fi.info.Mode() }
+func (fi *encFileInfo) ModTime() time.Time { return fi.info.ModTime() }
+func (fi *encFileInfo) IsDir() bool        { return fi.info.IsDir() }
+func (fi *encFileInfo) Sys() any           { return nil }
+
+// encFile is an open regular file. It supports Read, Seek and ReadAt.
+type encFile struct {
+	*io.SectionReader
+	f    fs.File
+	sr   *SeekableReader
+	info *encFileInfo
+}
+
+func (f *encFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *encFile) Close() error               { return f.f.Close() }
+
+// encChunkCache is an io.ReaderAt over a SeekableReader that keeps the
+// most recently decrypted chunk, so that the small sequential reads
+// of an open file decrypt each chunk once. Chunks are read and
+// decrypted outside the lock, so concurrent ReadAt calls still run in
+// parallel.
+type encChunkCache struct {
+	sr  *SeekableReader
+	mu  sync.Mutex
+	idx int64 // chunk held in buf, or -1
+	buf []byte
+}
+
+func (c *encChunkCache) ReadAt(p []byte, off int64) (int, error) {
+	s := c.sr
+	if off < 0 || off >= s.size {
+		return s.ReadAt(p, off)
+	}
+	n := 0
+	for n < len(p) && off < s.size {
+		i := off / s.chunkSize
+		c.mu.Lock()
+		plain := c.buf
+		hit := c.idx == i
+		c.mu.Unlock()
+		if !hit {
+			var err error
+			if plain, err = s.chunk(nil, i); err != nil {
+				return n, err
+			}
+			c.mu.Lock()
+			c.idx, c.buf = i, plain
+			c.mu.Unlock()
+		}
+		m := copy(p[n:], plain[off-i*s.chunkSize:])
+		n += m
+		off += int64(m)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+type encDirEntry struct {
+	info *encFileInfo
+}
+
+func (d *encDirEntry) Name() string               { return d.info.name }
+func (d *encDirEntry) IsDir() bool                { return d.info.IsDir() }
+func (d *encDirEntry) Type() fs.FileMode          { return d.info.Mode().Type() }
+func (d *encDirEntry) Info() (fs.FileInfo, error) { return d.info, nil }
+
+// encDir is an open directory. Its entries are decrypted and sorted
+// by name on the first call to ReadDir.
+type encDir struct {
+	fs      *EncryptedFS
+	f       fs.File
+	name    string
+	encPath string
+	info    *encFileInfo
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (d *encDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *encDir) Close() error               { return d.f.Close() }
+
+func (d *encDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *encDir) load() error {
+	rd, ok := d.f.(fs.ReadDirFile)
+	if !ok {
+		return &fs.PathError{Op: "readdir", Path: d.name, Err: errors.New("not implemented")}
+	}
+	entries, err := rd.ReadDir(-1)
+	if err != nil {
+		return err
+	}
+	for _, ent := range entries {
+		name, err := d.fs.decryptName(d.encPath, ent.Name())
+		if err != nil {
+			return &fs.PathError{Op: "readdir", Path: path.Join(d.name, ent.Name()), Err: err}
+		}
+		info, err := ent.Info()
+		if err != nil {
+			return err
+		}
+		fi := &encFileInfo{name: name, info: info, size: info.Size()}
+		if !info.IsDir() {
+			fi.size = d.fs.plainSize(info.Size())
+		}
+		d.entries = append(d.entries, &encDirEntry{fi})
+	}
+	slices.SortFunc(d.entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return nil
+}
+
+// ReadDir implements fs.ReadDirFile.
+func (d *encDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		d.read = true
+		if err := d.load(); err != nil {
+			return nil, err
+		}
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package simonspeck
+
+import "crypto/cipher"
+
+// feistelRounds is the number of rounds used for small-domain
+// encryption, as in NIST's FF1.
+const feistelRounds = 10
+
+// feistel is a balanced Feistel network over two halves of up to 64
+// bits each, with a block cipher as the round function. It lets us
+// encrypt domains that don't match any block length, such as the 24
+// free bits of a MAC address or the 122 free bits of a UUID.
+type feistel struct {
+	block cipher.Block
+	bits  uint // width of each half
+}
+
+// round computes the round function for round i. The cipher input is
+// the round number, then the tweak, then r right-aligned; the output
+// is the leading bytes of the ciphertext, truncated to f.bits. The
+// caller must ensure that all of this fits in one block.
+func (f *feistel) round(i int, tweak []byte, r uint64) uint64 {
+	var buf [16]byte
+	bs := f.block.BlockSize()
+	n := int(f.bits+7) / 8
+	buf[0] = byte(i)
+	copy(buf[1:], tweak)
+	for j := 0; j < n; j++ {
+		buf[bs-1-j] = byte(r >> uint(8*j))
+	}
+	f.block.Encrypt(buf[:bs], buf[:bs])
+	out := uint64(0)
+	for j := 0; j < n; j++ {
+		out = out<<8 | uint64(buf[j])
+	}
+	return out & (1<<f.bits - 1)
+}
+
+func (f *feistel) encrypt(tweak []byte, l, r uint64) (uint64, uint64) {
+	for i := 0; i < feistelRounds; i++ {
+		l, r = r, l^f.round(i, tweak, r)
+	}
+	return l, r
+}
+
+func (f *feistel) decrypt(tweak []byte, l, r uint64) (uint64, uint64) {
+	for i := feistelRounds - 1; i >= 0; i-- {
+		l, r = r^f.round(i, tweak, l), l
+	}
+	return l, r
+}
@@ -0,0 +1,94 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package simonspeck
+
+// gfPoly returns the low-order terms of the irreducible polynomial
+// used to define GF(2^n) for a block of size bytes. These are
+// irreducible pentanomials from Seroussi's table of low-weight binary
+// irreducible polynomials, and agree with CMAC for 64- and 128-bit
+// blocks. Elements are big-endian bit strings, so
+// doubling is a left shift.
+func gfPoly(size int) uint16 {
+	switch size {
+	case 4:
+		return 0x8d // x^32 + x^7 + x^3 + x^2 + 1
+	case 6:
+		return 0x2d // x^48 + x^5 + x^3 + x^2 + 1
+	case 8:
+		return 0x1b // x^64 + x^4 + x^3 + x + 1
+	case 12:
+		return 0x641 // x^96 + x^10 + x^9 + x^6 + 1
+	case 16:
+		return 0x87 // x^128 + x^7 + x^2 + x + 1
+	}
+	panic("simonspeck: unsupported block size for GF(2^n) arithmetic")
+}
+
+// gfDouble sets dst to src multiplied by x. Dst and src may point at
+// the same memory.
+func gfDouble(dst, src []byte) {
+	n := len(src)
+	r := gfPoly(n)
+	mask := -(src[0] >> 7)
+	for i := 0; i < n-1; i++ {
+		dst[i] = src[i]<<1 | src[i+1]>>7
+	}
+	dst[n-1] = src[n-1] << 1
+	dst[n-1] ^= byte(r) & mask
+	dst[n-2] ^= byte(r>>8) & mask
+}
+
+// gfMul sets dst to the product of a and b. Dst may alias a or b.
+func gfMul(dst, a, b []byte) {
+	z := make([]byte, len(a))
+	for i := 0; i < 8*len(b); i++ {
+		gfDouble(z, z)
+		mask := -((b[i/8] >> uint(7-i%8)) & 1)
+		for j := range z {
+			z[j] ^= a[j] & mask
+		}
+	}
+	copy(dst, z)
+}
+
+// gfMulPowX sets dst to src multiplied by x^e, by square and
+// multiply. Dst and src may point at the same memory.
+func gfMulPowX(dst, src []byte, e uint64) {
+	n := len(src)
+	p := make([]byte, n) // x^e
+	p[n-1] = 1
+	base := make([]byte, n) // x^(2^k)
+	base[n-1] = 2
+	for ; e != 0; e >>= 1 {
+		if e&1 != 0 {
+			gfMul(p, p, base)
+		}
+		gfMul(base, base, base)
+	}
+	gfMul(dst, src, p)
+}
+
+// gfHalve sets dst to src multiplied by x^-1. Dst and src may point
+// at the same memory.
+func gfHalve(dst, src []byte) {
+	n := len(src)
+	r := gfPoly(n)
+	mask := -(src[n-1] & 1)
+	var carry byte
+	// Adding the polynomial first makes the constant term zero, so
+	// the shift is exact; its x^n term becomes the top bit.
+	for i := 0; i < n; i++ {
+		b := src[i]
+		switch i {
+		case n - 1:
+			b ^= byte(r) & mask
+		case n - 2:
+			b ^= byte(r>>8) & mask
+		}
+		dst[i] = b>>1 | carry
+		carry = b << 7
+	}
+	dst[0] |= 0x80 & mask
+}
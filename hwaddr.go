@@ -0,0 +1,107 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package simonspeck
+
+import (
+	"crypto/cipher"
+	"errors"
+	"net"
+)
+
+// ErrInvalidHardwareAddr is returned for a hardware address that is not EUI-48.
+var ErrInvalidHardwareAddr = errors.New("simonspeck: hardware address is not EUI-48")
+
+// MACPreserve selects which parts of a MAC address a MACPseudonymizer
+// leaves untouched.
+type MACPreserve int
+
+const (
+	// PreserveNone encrypts all 48 bits.
+	PreserveNone MACPreserve = iota
+	// PreserveFlags keeps the multicast (I/G) and locally
+	// administered (U/L) bits of the first octet.
+	PreserveFlags
+	// PreserveOUI keeps the 24-bit vendor prefix, and with it the
+	// flag bits, and encrypts only the device-specific half.
+	PreserveOUI
+)
+
+// MACPseudonymizer maps EUI-48 hardware addresses to pseudonymous
+// addresses of the same length using a 48-bit block cipher (Simon48
+// or Speck48). The mapping is a permutation, so it can be reversed by
+// anyone holding the key.
+type MACPseudonymizer struct {
+	block    cipher.Block
+	preserve MACPreserve
+	f        feistel
+}
+
+// NewMACPseudonymizer creates and returns a new MACPseudonymizer. The
+// block cipher must have a 48-bit block length.
+func NewMACPseudonymizer(block cipher.Block, preserve MACPreserve) *MACPseudonymizer {
+	if block.BlockSize() != 6 {
+		panic("NewMACPseudonymizer() requires a 48-bit block cipher")
+	}
+	return &MACPseudonymizer{
+		block:    block,
+		preserve: preserve,
+		f:        feistel{block: block, bits: 12},
+	}
+}
+
+// Pseudonymize returns the pseudonym of hw.
+func (m *MACPseudonymizer) Pseudonymize(hw net.HardwareAddr) (net.HardwareAddr, error) {
+	return m.crypt(hw, true)
+}
+
+// Restore returns the address whose pseudonym is hw.
+func (m *MACPseudonymizer) Restore(hw net.HardwareAddr) (net.HardwareAddr, error) {
+	return m.crypt(hw, false)
+}
+
+func (m *MACPseudonymizer) crypt(hw net.HardwareAddr, encrypt bool) (net.HardwareAddr, error) {
+	if len(hw) != 6 {
+		return nil, ErrInvalidHardwareAddr
+	}
+	out := make(net.HardwareAddr, 6)
+	copy(out, hw)
+	switch m.preserve {
+	case PreserveOUI:
+		// The lower 24 bits are split into two 12-bit halves and
+		// run through a Feistel network tweaked by the OUI.
+		l := uint64(out[3])<<4 | uint64(out[4]>>4)
+		r := uint64(out[4]&0x0f)<<8 | uint64(out[5])
+		if encrypt {
+			l, r = m.f.encrypt(out[:3], l, r)
+		} else {
+			l, r = m.f.decrypt(out[:3], l, r)
+		}
+		out[3] = byte(l >> 4)
+		out[4] = byte(l<<4) | byte(r>>8)
+		out[5] = byte(r)
+	case PreserveFlags:
+		// Cycle walking: the cipher restricted to addresses with the
+		// same two flag bits is still a permutation. On average it
+		// takes four block operations.
+		flags := out[0] & 3
+		for {
+			m.cryptBlock(out, encrypt)
+			if out[0]&3 == flags {
+				break
+			}
+		}
+	default:
+		m.cryptBlock(out, encrypt)
+	}
+	return out, nil
+}
+
+func (m *MACPseudonymizer) cryptBlock(b []byte, encrypt bool) {
+	if encrypt {
+		m.block.Encrypt(b, b)
+	} else {
+		m.block.Decrypt(b, b)
+	}
+}
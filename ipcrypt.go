@@ -0,0 +1,138 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package simonspeck
+
+import (
+	"crypto/rand"
+	"errors"
+	"net/netip"
+)
+
+// AddrTweakSize is the length of the tweak used by the
+// non-deterministic address encryption functions.
+const AddrTweakSize = 8
+
+var (
+	// ErrInvalidAddr is returned for the zero netip.Addr.
+	ErrInvalidAddr = errors.New("simonspeck: invalid IP address")
+	// ErrTweakSize is returned for a tweak that is not AddrTweakSize bytes.
+	ErrTweakSize = errors.New("simonspeck: invalid tweak size")
+)
+
+// AddrCipher encrypts IP addresses into IP addresses of the same
+// family, in the style of ipcrypt. An IPv4 address is exactly one
+// Speck32/64 block and an IPv6 address exactly one Speck128/128
+// block, so the ciphertext always fits the address format. IPv4
+// addresses embedded in IPv6 (::ffff:a.b.c.d) are treated as IPv6.
+type AddrCipher struct {
+	v4   *Speck32Cipher
+	v6   *Speck128Cipher
+	mask *Speck128Cipher
+}
+
+// NewAddrCipher creates and returns a new AddrCipher from a 128-bit
+// key. The key is used as is for Speck128/128; the Speck32/64 key and
+// the key used to turn tweaks into masks are derived from it by
+// encrypting distinct constant blocks.
+func NewAddrCipher(key []byte) *AddrCipher {
+	if len(key) != 16 {
+		panic("NewAddrCipher() requires a 128-bit key")
+	}
+	c := new(AddrCipher)
+	c.v6 = NewSpeck128(key)
+	var block [16]byte
+	block[0] = 1
+	c.v6.Encrypt(block[:], block[:])
+	c.v4 = NewSpeck32(block[:8])
+	block = [16]byte{0: 2}
+	c.v6.Encrypt(block[:], block[:])
+	c.mask = NewSpeck128(block[:])
+	return c
+}
+
+// Encrypt deterministically encrypts addr. The same address always
+// encrypts to the same result under a given key.
+func (c *AddrCipher) Encrypt(addr netip.Addr) (netip.Addr, error) {
+	return c.crypt(addr, nil, true)
+}
+
+// Decrypt reverses Encrypt.
+func (c *AddrCipher) Decrypt(addr netip.Addr) (netip.Addr, error) {
+	return c.crypt(addr, nil, false)
+}
+
+// EncryptWithTweak encrypts addr under an AddrTweakSize-byte tweak.
+// Equal addresses encrypted with different tweaks give unrelated
+// results; the tweak is needed again to decrypt and is not secret.
+func (c *AddrCipher) EncryptWithTweak(addr netip.Addr, tweak []byte) (netip.Addr, error) {
+	if len(tweak) != AddrTweakSize {
+		return netip.Addr{}, ErrTweakSize
+	}
+	return c.crypt(addr, tweak, true)
+}
+
+// DecryptWithTweak reverses EncryptWithTweak.
+func (c *AddrCipher) DecryptWithTweak(addr netip.Addr, tweak []byte) (netip.Addr, error) {
+	if len(tweak) != AddrTweakSize {
+		return netip.Addr{}, ErrTweakSize
+	}
+	return c.crypt(addr, tweak, false)
+}
+
+// EncryptRandomized is the non-deterministic variant of Encrypt. It
+// picks a fresh random tweak, which the caller must store alongside
+// the encrypted address in order to decrypt it with DecryptWithTweak.
+func (c *AddrCipher) EncryptRandomized(addr netip.Addr) (netip.Addr, []byte, error) {
+	tweak := make([]byte, AddrTweakSize)
+	if _, err := rand.Read(tweak); err != nil {
+		return netip.Addr{}, nil, err
+	}
+	enc, err := c.EncryptWithTweak(addr, tweak)
+	if err != nil {
+		return netip.Addr{}, nil, err
+	}
+	return enc, tweak, nil
+}
+
+// crypt encrypts or decrypts a single address. A tweak is applied as
+// a mask before and after the block cipher, so that
+// E(p ^ mask) ^ mask is still a permutation of the address space.
+func (c *AddrCipher) crypt(addr netip.Addr, tweak []byte, encrypt bool) (netip.Addr, error) {
+	if !addr.IsValid() {
+		return netip.Addr{}, ErrInvalidAddr
+	}
+	var mask [16]byte
+	if tweak != nil {
+		copy(mask[:], tweak)
+		c.mask.Encrypt(mask[:], mask[:])
+	}
+	if addr.Is4() {
+		b := addr.As4()
+		xorBytes(b[:], b[:], mask[:4])
+		if encrypt {
+			c.v4.Encrypt(b[:], b[:])
+		} else {
+			c.v4.Decrypt(b[:], b[:])
+		}
+		xorBytes(b[:], b[:], mask[:4])
+		return netip.AddrFrom4(b), nil
+	}
+	b := addr.As16()
+	xorBytes(b[:], b[:], mask[:])
+	if encrypt {
+		c.v6.Encrypt(b[:], b[:])
+	} else {
+		c.v6.Decrypt(b[:], b[:])
+	}
+	xorBytes(b[:], b[:], mask[:])
+	return netip.AddrFrom16(b).WithZone(addr.Zone()), nil
+}
+
+// xorBytes sets dst[i] = a[i] ^ b[i] for every i < len(dst).
+func xorBytes(dst, a, b []byte) {
+	for i := range dst {
+		dst[i] = a[i] ^ b[i]
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package simonspeck
+
+import (
+	"encoding/binary"
+	"hash"
+)
+
+// The key derivation functions below are those of NIST SP 800-108.
+// The PRF is a keyed hash.Hash, normally NewCMAC over one of the
+// ciphers of this package keyed with the key-derivation key, e.g.
+//
+//	key := CounterKDF(NewCMAC(NewSpeck128(master)), []byte("session"), deviceID, 32)
+//
+// The fixed input data is Label || 0x00 || Context || [L]_32, where L
+// is the output length in bits, and the iteration counter [i]_32 is a
+// 32-bit big-endian integer starting at 1.
+
+// fixedInput returns Label || 0x00 || Context || [L]_32.
+func fixedInput(label, context []byte, length int) []byte {
+	if length < 0 || uint64(length) > (1<<32-1)/8 {
+		panic("simonspeck: invalid KDF output length")
+	}
+	in := append([]byte(nil), label...)
+	in = append(in, 0)
+	in = append(in, context...)
+	return binary.BigEndian.AppendUint32(in, uint32(8*length))
+}
+
+// CounterKDF derives length bytes in counter mode:
+// K(i) = PRF(K_I, [i]_32 || Label || 0x00 || Context || [L]_32).
+func CounterKDF(prf hash.Hash, label, context []byte, length int) []byte {
+	fixed := fixedInput(label, context, length)
+	out := make([]byte, 0, length+prf.Size())
+	for i := uint32(1); len(out) < length; i++ {
+		prf.Reset()
+		prf.Write(binary.BigEndian.AppendUint32(nil, i))
+		prf.Write(fixed)
+		out = prf.Sum(out)
+	}
+	return out[:length]
+}
+
+// FeedbackKDF derives length bytes in feedback mode with the counter
+// included: K(0) = iv and
+// K(i) = PRF(K_I, K(i-1) || [i]_32 || Label || 0x00 || Context || [L]_32).
+// The iv may be empty.
+func FeedbackKDF(prf hash.Hash, iv, label, context []byte, length int) []byte {
+	fixed := fixedInput(label, context, length)
+	out := make([]byte, 0, length+prf.Size())
+	k := iv
+	for i := uint32(1); len(out) < length; i++ {
+		prf.Reset()
+		prf.Write(k)
+		prf.Write(binary.BigEndian.AppendUint32(nil, i))
+		prf.Write(fixed)
+		out = prf.Sum(out)
+		k = out[len(out)-prf.Size():]
+	}
+	return out[:length]
+}
+
+// DoublePipelineKDF derives length bytes in double-pipeline iteration
+// mode with the counter included: A(0) = Label || 0x00 || Context ||
+// [L]_32, A(i) = PRF(K_I, A(i-1)) and
+// K(i) = PRF(K_I, A(i) || [i]_32 || Label || 0x00 || Context || [L]_32).
+func DoublePipelineKDF(prf hash.Hash, label, context []byte, length int) []byte {
+	fixed := fixedInput(label, context, length)
+	out := make([]byte, 0, length+prf.Size())
+	a := fixed
+	for i := uint32(1); len(out) < length; i++ {
+		prf.Reset()
+		prf.Write(a)
+		a = prf.Sum(nil)
+		prf.Reset()
+		prf.Write(a)
+		prf.Write(binary.BigEndian.AppendUint32(nil, i))
+		prf.Write(fixed)
+		out = prf.Sum(out)
+	}
+	return out[:length]
+}
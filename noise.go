@@ -0,0 +1,242 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package simonspeck
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// The types below implement the CipherState and SymmetricState objects
+// of the Noise Protocol Framework, revision 34, with cipher functions
+// built on Speck128 and with SHA-256 as the hash function. Handshake
+// patterns and DH functions are left to the caller, e.g. crypto/ecdh
+// for "25519".
+
+// ErrNoiseNonce is returned once a CipherState has used its last nonce.
+var ErrNoiseNonce = errors.New("simonspeck: Noise nonce exhausted")
+
+// NoiseCipherFunc is a set of Noise cipher functions: an AEAD keyed
+// with a 32-byte key. The 64-bit Noise nonce is encoded big-endian in
+// the last eight bytes of the AEAD nonce, and the rest is zero, as for
+// AESGCM. REKEY is the default one of the specification.
+type NoiseCipherFunc struct {
+	// Name is the name of the cipher functions in protocol names.
+	Name string
+	// New returns the AEAD for a 32-byte key.
+	New func(key []byte) cipher.AEAD
+}
+
+var (
+	// NoiseSpeckGCM is GCM over Speck128/256, like AESGCM.
+	NoiseSpeckGCM = NoiseCipherFunc{Name: "SpeckGCM", New: newNoiseSpeckGCM}
+	// NoiseSpeckSIV is NewSIV with Speck128/128 keyed with the two
+	// halves of the key. A repeated nonce, after restoring saved state
+	// for example, only reveals whether two messages were equal.
+	NoiseSpeckSIV = NoiseCipherFunc{Name: "SpeckSIV", New: newNoiseSpeckSIV}
+)
+
+func newNoiseSpeckGCM(key []byte) cipher.AEAD {
+	aead, err := cipher.NewGCM(NewSpeck128(key))
+	if err != nil {
+		panic(err)
+	}
+	return aead
+}
+
+func newNoiseSpeckSIV(key []byte) cipher.AEAD {
+	return NewSIV(NewSpeck128(key[:16]), NewSpeck128(key[16:]))
+}
+
+// NoiseCipherState is a Noise CipherState: a key, possibly empty, and
+// a nonce.
+type NoiseCipherState struct {
+	cf   NoiseCipherFunc
+	k    []byte
+	aead cipher.AEAD
+	n    uint64
+}
+
+// NewNoiseCipherState returns a NoiseCipherState with an empty key.
+func NewNoiseCipherState(cf NoiseCipherFunc) *NoiseCipherState {
+	return &NoiseCipherState{cf: cf}
+}
+
+// InitializeKey sets the key, which must be 32 bytes or nil for the
+// empty key, and resets the nonce to zero.
+func (c *NoiseCipherState) InitializeKey(key []byte) {
+	c.n = 0
+	if key == nil {
+		c.k, c.aead = nil, nil
+		return
+	}
+	if len(key) != 32 {
+		panic("simonspeck: Noise keys must be 32 bytes")
+	}
+	c.k = append([]byte(nil), key...)
+	c.aead = c.cf.New(c.k)
+}
+
+// HasKey reports whether the key is non-empty.
+func (c *NoiseCipherState) HasKey() bool { return c.aead != nil }
+
+// SetNonce sets the nonce.
+func (c *NoiseCipherState) SetNonce(n uint64) { c.n = n }
+
+func (c *NoiseCipherState) nonce(n uint64) []byte {
+	nonce := make([]byte, c.aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], n)
+	return nonce
+}
+
+// EncryptWithAd encrypts plaintext, or returns it unchanged if the key
+// is empty, and increments the nonce. The nonce 2^64-1 is reserved,
+// so ErrNoiseNonce is returned when it is reached.
+func (c *NoiseCipherState) EncryptWithAd(ad, plaintext []byte) ([]byte, error) {
+	if !c.HasKey() {
+		return append([]byte(nil), plaintext...), nil
+	}
+	if c.n == math.MaxUint64 {
+		return nil, ErrNoiseNonce
+	}
+	out := c.aead.Seal(nil, c.nonce(c.n), plaintext, ad)
+	c.n++
+	return out, nil
+}
+
+// DecryptWithAd reverses EncryptWithAd. The nonce is only incremented
+// if authentication succeeds; otherwise ErrOpen is returned.
+func (c *NoiseCipherState) DecryptWithAd(ad, ciphertext []byte) ([]byte, error) {
+	if !c.HasKey() {
+		return append([]byte(nil), ciphertext...), nil
+	}
+	if c.n == math.MaxUint64 {
+		return nil, ErrNoiseNonce
+	}
+	out, err := c.aead.Open(nil, c.nonce(c.n), ciphertext, ad)
+	if err != nil {
+		return nil, ErrOpen
+	}
+	c.n++
+	return out, nil
+}
+
+// Rekey replaces the key with the first 32 bytes of the encryption of
+// 32 zero bytes under the reserved nonce 2^64-1. The nonce is kept.
+func (c *NoiseCipherState) Rekey() {
+	if !c.HasKey() {
+		panic("simonspeck: Rekey of a NoiseCipherState without a key")
+	}
+	k := c.aead.Seal(nil, c.nonce(math.MaxUint64), make([]byte, 32), nil)
+	n := c.n
+	c.InitializeKey(k[:32])
+	c.n = n
+}
+
+// NoiseSymmetricState is a Noise SymmetricState with SHA-256 as the
+// hash function.
+type NoiseSymmetricState struct {
+	cs    *NoiseCipherState
+	ck, h []byte
+}
+
+// NewNoiseSymmetricState runs InitializeSymmetric for the full
+// protocol name, such as "Noise_XX_25519_SpeckGCM_SHA256".
+func NewNoiseSymmetricState(protocolName string, cf NoiseCipherFunc) *NoiseSymmetricState {
+	s := &NoiseSymmetricState{cs: NewNoiseCipherState(cf)}
+	if len(protocolName) <= sha256.Size {
+		s.h = make([]byte, sha256.Size)
+		copy(s.h, protocolName)
+	} else {
+		sum := sha256.Sum256([]byte(protocolName))
+		s.h = sum[:]
+	}
+	s.ck = append([]byte(nil), s.h...)
+	return s
+}
+
+// noiseHKDF returns the first n (2 or 3) outputs of the Noise HKDF
+// with chaining key ck.
+func noiseHKDF(ck, ikm []byte, n int) [][]byte {
+	mac := hmac.New(sha256.New, ck)
+	mac.Write(ikm)
+	tempKey := mac.Sum(nil)
+	mac = hmac.New(sha256.New, tempKey)
+	out := make([][]byte, n)
+	var prev []byte
+	for i := range out {
+		mac.Reset()
+		mac.Write(prev)
+		mac.Write([]byte{byte(i + 1)})
+		out[i] = mac.Sum(nil)
+		prev = out[i]
+	}
+	return out
+}
+
+// MixKey mixes input key material, such as a DH output, into the
+// chaining key and sets a new cipher key.
+func (s *NoiseSymmetricState) MixKey(ikm []byte) {
+	out := noiseHKDF(s.ck, ikm, 2)
+	s.ck = out[0]
+	s.cs.InitializeKey(out[1])
+}
+
+// MixHash mixes data into the handshake hash.
+func (s *NoiseSymmetricState) MixHash(data []byte) {
+	h := sha256.New()
+	h.Write(s.h)
+	h.Write(data)
+	s.h = h.Sum(nil)
+}
+
+// MixKeyAndHash mixes input key material, such as a PSK, into both the
+// chaining key and the handshake hash, and sets a new cipher key.
+func (s *NoiseSymmetricState) MixKeyAndHash(ikm []byte) {
+	out := noiseHKDF(s.ck, ikm, 3)
+	s.ck = out[0]
+	s.MixHash(out[1])
+	s.cs.InitializeKey(out[2])
+}
+
+// HandshakeHash returns the handshake hash, for channel binding.
+func (s *NoiseSymmetricState) HandshakeHash() []byte {
+	return append([]byte(nil), s.h...)
+}
+
+// EncryptAndHash encrypts plaintext with the handshake hash as
+// additional data, and mixes the ciphertext into the hash.
+func (s *NoiseSymmetricState) EncryptAndHash(plaintext []byte) ([]byte, error) {
+	ciphertext, err := s.cs.EncryptWithAd(s.h, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	s.MixHash(ciphertext)
+	return ciphertext, nil
+}
+
+// DecryptAndHash reverses EncryptAndHash.
+func (s *NoiseSymmetricState) DecryptAndHash(ciphertext []byte) ([]byte, error) {
+	plaintext, err := s.cs.DecryptWithAd(s.h, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	s.MixHash(ciphertext)
+	return plaintext, nil
+}
+
+// Split returns the two CipherStates for transport messages: the
+// first for the initiator to send, the second for the responder.
+func (s *NoiseSymmetricState) Split() (*NoiseCipherState, *NoiseCipherState) {
+	out := noiseHKDF(s.ck, nil, 2)
+	c1, c2 := NewNoiseCipherState(s.cs.cf), NewNoiseCipherState(s.cs.cf)
+	c1.InitializeKey(out[0])
+	c2.InitializeKey(out[1])
+	return c1, c2
+}
@@ -0,0 +1,175 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package simonspeck
+
+import (
+	"crypto/cipher"
+	"hash"
+	"math/bits"
+	"runtime"
+	"sync"
+)
+
+// pmacParallelMin is the number of blocks each goroutine gets when a
+// Write is long enough to be spread over several.
+const pmacParallelMin = 4096
+
+// pmac implements PMAC1, the refined form of the PMAC of Black and
+// Rogaway ("A Block-Cipher Mode of Operation for Parallelizable
+// Message Authentication", Eurocrypt 2002). Unlike CMAC, the
+// encryption of each block depends only on the block and its
+// position, so blocks can be processed in any order or in parallel.
+// Write uses this: a long write is split into runs of at least
+// pmacParallelMin blocks, processed by up to GOMAXPROCS goroutines,
+// each starting from the offset of its first block.
+type pmac struct {
+	block  cipher.Block
+	l      [][]byte // l[i] = L * x^i
+	lInv   []byte   // L * x^-1
+	offset []byte
+	sum    []byte
+	buf    []byte
+	n      int
+	count  uint64 // blocks processed
+	tmp    []byte
+}
+
+// NewPMAC returns a hash.Hash computing PMAC1 with the given block
+// cipher, which may have any block length in this package. The tag is
+// one block long. Long writes call block.Encrypt from several
+// goroutines at once, which the ciphers of this package and
+// crypto/aes allow.
+func NewPMAC(block cipher.Block) hash.Hash {
+	bs := block.BlockSize()
+	p := &pmac{
+		block:  block,
+		lInv:   make([]byte, bs),
+		offset: make([]byte, bs),
+		sum:    make([]byte, bs),
+		buf:    make([]byte, bs),
+		tmp:    make([]byte, bs),
+	}
+	l := make([]byte, bs)
+	block.Encrypt(l, l)
+	gfHalve(p.lInv, l)
+	// ntz(i) < 64 for every block count we can reach.
+	p.l = make([][]byte, 64)
+	for i := range p.l {
+		p.l[i] = l
+		next := make([]byte, bs)
+		gfDouble(next, l)
+		l = next
+	}
+	return p
+}
+
+func (p *pmac) Size() int      { return len(p.sum) }
+func (p *pmac) BlockSize() int { return len(p.sum) }
+
+func (p *pmac) Reset() {
+	clear(p.offset)
+	clear(p.sum)
+	p.n = 0
+	p.count = 0
+}
+
+// processBlock folds the block in buf, which is known not to be the
+// last, into the running sum.
+func (p *pmac) processBlock() {
+	p.blocks(p.sum, p.offset, p.tmp, p.buf, p.count)
+	p.count++
+}
+
+// blocks folds the whole blocks of data, which follow block number
+// first, into sum. Offset must be the offset of block first, and is
+// left at that of the last block of data; tmp is scratch space.
+func (p *pmac) blocks(sum, offset, tmp, data []byte, first uint64) {
+	bs := len(sum)
+	for i := first + 1; len(data) > 0; i++ {
+		xorBytes(offset, offset, p.l[bits.TrailingZeros64(i)])
+		xorBytes(tmp, data[:bs], offset)
+		p.block.Encrypt(tmp, tmp)
+		xorBytes(sum, sum, tmp)
+		data = data[bs:]
+	}
+}
+
+// offsetAt sets dst to the offset of block i, L times the Gray code
+// of i, so that a run of blocks can start anywhere.
+func (p *pmac) offsetAt(dst []byte, i uint64) {
+	clear(dst)
+	for g := i ^ i>>1; g != 0; g &= g - 1 {
+		xorBytes(dst, dst, p.l[bits.TrailingZeros64(g)])
+	}
+}
+
+// bulk folds the whole blocks of data, none of them the last of the
+// message, into the running sum, in parallel if there are enough.
+func (p *pmac) bulk(data []byte) {
+	bs := len(p.buf)
+	k := len(data) / bs
+	workers := min(runtime.GOMAXPROCS(0), k/pmacParallelMin)
+	if workers < 2 {
+		p.blocks(p.sum, p.offset, p.tmp, data, p.count)
+		p.count += uint64(k)
+		return
+	}
+	per := (k + workers - 1) / workers
+	sums := make([][]byte, workers)
+	var wg sync.WaitGroup
+	for w := range sums {
+		start, end := w*per, min((w+1)*per, k)
+		sums[w] = make([]byte, bs)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			first := p.count + uint64(start)
+			offset := make([]byte, bs)
+			p.offsetAt(offset, first)
+			p.blocks(sums[w], offset, make([]byte, bs), data[start*bs:end*bs], first)
+		}()
+	}
+	wg.Wait()
+	for _, sum := range sums {
+		xorBytes(p.sum, p.sum, sum)
+	}
+	p.count += uint64(k)
+	p.offsetAt(p.offset, p.count)
+}
+
+func (p *pmac) Write(b []byte) (int, error) {
+	written := len(b)
+	bs := len(p.buf)
+	c := copy(p.buf[p.n:], b)
+	p.n += c
+	b = b[c:]
+	if len(b) == 0 {
+		return written, nil
+	}
+	// More data follows, so the buffered block is not the last. Fold
+	// it in, then all but the last block of b straight from b, and
+	// keep that one, which may end the message, buffered.
+	p.processBlock()
+	if k := (len(b) - 1) / bs; k > 0 {
+		p.bulk(b[:k*bs])
+		b = b[k*bs:]
+	}
+	p.n = copy(p.buf, b)
+	return written, nil
+}
+
+func (p *pmac) Sum(in []byte) []byte {
+	bs := len(p.buf)
+	x := make([]byte, bs)
+	copy(x, p.buf[:p.n])
+	if p.n == bs {
+		xorBytes(x, x, p.lInv)
+	} else {
+		x[p.n] = 0x80
+	}
+	xorBytes(x, x, p.sum)
+	p.block.Encrypt(x, x)
+	return append(in, x...)
+}
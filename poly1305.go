@@ -0,0 +1,205 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package simonspeck
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"math/bits"
+)
+
+// Poly1305 is not in the standard library, so this file carries its
+// own: a straightforward implementation with three 64-bit limbs.
+
+const (
+	rMask0 = 0x0ffffffc0fffffff
+	rMask1 = 0x0ffffffc0ffffffc
+)
+
+// poly1305Sum computes the Poly1305 authenticator of msg under the
+// one-time key r || s, i.e. (poly_r(msg) + s) mod 2^128.
+func poly1305Sum(out *[16]byte, msg []byte, r, s []byte) {
+	r0 := binary.LittleEndian.Uint64(r[0:8]) & rMask0
+	r1 := binary.LittleEndian.Uint64(r[8:16]) & rMask1
+	var h0, h1, h2 uint64
+
+	for len(msg) > 0 {
+		var block [16]byte
+		var hibit uint64 = 1
+		if len(msg) >= 16 {
+			copy(block[:], msg[:16])
+			msg = msg[16:]
+		} else {
+			copy(block[:], msg)
+			block[len(msg)] = 1
+			hibit = 0
+			msg = nil
+		}
+		var c uint64
+		h0, c = bits.Add64(h0, binary.LittleEndian.Uint64(block[0:8]), 0)
+		h1, c = bits.Add64(h1, binary.LittleEndian.Uint64(block[8:16]), c)
+		h2 += c + hibit
+
+		// h *= r, giving a 256-bit product m3:m2:m1:m0. h2 is at most
+		// 7 and r0, r1 are below 2^60, so h2*r0 and h2*r1 fit.
+		h0r0hi, h0r0lo := bits.Mul64(h0, r0)
+		h1r0hi, h1r0lo := bits.Mul64(h1, r0)
+		h0r1hi, h0r1lo := bits.Mul64(h0, r1)
+		h1r1hi, h1r1lo := bits.Mul64(h1, r1)
+		h2r0 := h2 * r0
+		h2r1 := h2 * r1
+
+		m0 := h0r0lo
+		m1, c := bits.Add64(h0r0hi, h1r0lo, 0)
+		m2, c2 := bits.Add64(h1r0hi, h1r1lo, c)
+		m3 := h1r1hi + c2
+		m1, c = bits.Add64(m1, h0r1lo, 0)
+		m2, c = bits.Add64(m2, h0r1hi, c)
+		m3 += c
+		m2, c = bits.Add64(m2, h2r0, 0)
+		m3 += h2r1 + c
+
+		// Reduce modulo 2^130 - 5: the bits above 130, times 5, are
+		// added to the low 130 bits as 4x + x.
+		h0, h1, h2 = m0, m1, m2&3
+		cc0, cc1 := m2&^3, m3
+		h0, c = bits.Add64(h0, cc0, 0)
+		h1, c = bits.Add64(h1, cc1, c)
+		h2 += c
+		cc0, cc1 = cc0>>2|cc1<<62, cc1>>2
+		h0, c = bits.Add64(h0, cc0, 0)
+		h1, c = bits.Add64(h1, cc1, c)
+		h2 += c
+	}
+
+	// Subtract p if h >= p, in constant time.
+	t0, b := bits.Sub64(h0, 0xfffffffffffffffb, 0)
+	t1, b := bits.Sub64(h1, 0xffffffffffffffff, b)
+	_, b = bits.Sub64(h2, 3, b)
+	mask := b - 1 // all ones if there was no borrow
+	h0 = h0&^mask | t0&mask
+	h1 = h1&^mask | t1&mask
+
+	var c uint64
+	h0, c = bits.Add64(h0, binary.LittleEndian.Uint64(s[0:8]), 0)
+	h1, _ = bits.Add64(h1, binary.LittleEndian.Uint64(s[8:16]), c)
+	binary.LittleEndian.PutUint64(out[0:8], h0)
+	binary.LittleEndian.PutUint64(out[8:16], h1)
+}
+
+// Poly1305MAC is Bernstein's Poly1305-AES nonce-based MAC with a
+// 128-bit block cipher (Speck128 or Simon128) in place of AES: the
+// tag of a message m under nonce n is poly_r(m) + E_k(n) mod 2^128.
+// Each nonce must be used for at most one message under a key.
+type Poly1305MAC struct {
+	block cipher.Block
+	r     [16]byte
+}
+
+// NewPoly1305MAC returns a Poly1305MAC from block, the cipher keyed
+// with k, and the 16-byte secret r. The bits of r that Poly1305
+// requires to be clear are cleared.
+func NewPoly1305MAC(block cipher.Block, r []byte) *Poly1305MAC {
+	if block.BlockSize() != 16 || len(r) != 16 {
+		panic("NewPoly1305MAC() requires a 128-bit block cipher and a 128-bit r")
+	}
+	m := &Poly1305MAC{block: block}
+	copy(m.r[:], r)
+	return m
+}
+
+// Sum appends the 16-byte tag of msg under the 16-byte nonce to dst.
+func (m *Poly1305MAC) Sum(dst, nonce, msg []byte) []byte {
+	if len(nonce) != 16 {
+		panic("simonspeck: Poly1305MAC requires a 16-byte nonce")
+	}
+	var s [16]byte
+	m.block.Encrypt(s[:], nonce)
+	var tag [16]byte
+	poly1305Sum(&tag, msg, m.r[:], s[:])
+	return append(dst, tag[:]...)
+}
+
+// Verify reports in constant time whether tag is the tag of msg under
+// nonce.
+func (m *Poly1305MAC) Verify(tag, nonce, msg []byte) bool {
+	return subtle.ConstantTimeCompare(m.Sum(nil, nonce, msg), tag) == 1
+}
+
+const (
+	poly1305NonceSize = 12
+	poly1305TagSize   = 16
+	// The 32-bit block counter starts at 1, the all-zero counter
+	// being reserved for the MAC nonce.
+	poly1305MaxPlaintext = (1<<32 - 2) * 16
+)
+
+type poly1305AEAD struct {
+	mac *Poly1305MAC
+}
+
+// NewPoly1305AEAD returns an AEAD that encrypts with the block cipher
+// in CTR mode and authenticates with Poly1305MAC, laid out like
+// ChaCha20-Poly1305 (RFC 8439). The 12-byte nonce N gives the CTR
+// blocks N || 1, N || 2, ... and the MAC nonce N || 0, so the two
+// never share a cipher input. The Poly1305 input is the additional
+// data and the ciphertext, each padded to 16 bytes, followed by their
+// lengths as 64-bit little-endian integers.
+func NewPoly1305AEAD(block cipher.Block, r []byte) cipher.AEAD {
+	return &poly1305AEAD{mac: NewPoly1305MAC(block, r)}
+}
+
+func (a *poly1305AEAD) NonceSize() int { return poly1305NonceSize }
+func (a *poly1305AEAD) Overhead() int  { return poly1305TagSize }
+
+func (a *poly1305AEAD) nonces(nonce []byte) (macNonce, ctrIV []byte) {
+	if len(nonce) != poly1305NonceSize {
+		panic("simonspeck: incorrect nonce length given to Poly1305 AEAD")
+	}
+	macNonce = make([]byte, 16)
+	copy(macNonce, nonce)
+	ctrIV = append([]byte(nil), macNonce...)
+	ctrIV[15] = 1
+	return
+}
+
+func (a *poly1305AEAD) tag(macNonce, ciphertext, additionalData []byte) []byte {
+	var pad [16]byte
+	msg := make([]byte, 0, len(additionalData)+len(ciphertext)+48)
+	msg = append(msg, additionalData...)
+	msg = append(msg, pad[:(16-len(additionalData)%16)%16]...)
+	msg = append(msg, ciphertext...)
+	msg = append(msg, pad[:(16-len(ciphertext)%16)%16]...)
+	msg = binary.LittleEndian.AppendUint64(msg, uint64(len(additionalData)))
+	msg = binary.LittleEndian.AppendUint64(msg, uint64(len(ciphertext)))
+	return a.mac.Sum(nil, macNonce, msg)
+}
+
+func (a *poly1305AEAD) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if uint64(len(plaintext)) > poly1305MaxPlaintext {
+		panic("simonspeck: message too large for Poly1305 AEAD")
+	}
+	macNonce, iv := a.nonces(nonce)
+	ret, out := sliceForAppend(dst, len(plaintext)+poly1305TagSize)
+	cipher.NewCTR(a.mac.block, iv).XORKeyStream(out, plaintext)
+	copy(out[len(plaintext):], a.tag(macNonce, out[:len(plaintext)], additionalData))
+	return ret
+}
+
+func (a *poly1305AEAD) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	macNonce, iv := a.nonces(nonce)
+	if len(ciphertext) < poly1305TagSize {
+		return nil, ErrOpen
+	}
+	tag := ciphertext[len(ciphertext)-poly1305TagSize:]
+	ciphertext = ciphertext[:len(ciphertext)-poly1305TagSize]
+	if subtle.ConstantTimeCompare(a.tag(macNonce, ciphertext, additionalData), tag) != 1 {
+		return nil, ErrOpen
+	}
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	cipher.NewCTR(a.mac.block, iv).XORKeyStream(out, ciphertext)
+	return ret, nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package simonspeck
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	seekableMagic   = "SSRA"
+	seekableVersion = 1
+)
+
+// ErrSeekableHeader is returned for a stream whose header is malformed.
+var ErrSeekableHeader = errors.New("simonspeck: invalid seekable stream header")
+
+// seekableHeader encodes the header of a seekable stream: the magic
+// string, a version byte, the 4-byte chunk size and the nonce prefix,
+// preceded by its length.
+func seekableHeader(noncePrefix []byte, chunkSize int) []byte {
+	h := []byte(seekableMagic)
+	h = append(h, seekableVersion)
+	h = binary.BigEndian.AppendUint32(h, uint32(chunkSize))
+	h = append(h, byte(len(noncePrefix)))
+	return append(h, noncePrefix...)
+}
+
+// NewSeekableWriter returns an io.WriteCloser that writes a seekable
+// encrypted stream to w, for reading back with NewSeekableReader. The
+// stream is a header recording the chunk size and nonce prefix,
+// followed by chunks sealed exactly as by NewStreamWriter, except
+// that the header is authenticated as additional data of every
+// chunk. Close seals the final chunk; it does not close w.
+func NewSeekableWriter(w io.Writer, aead cipher.AEAD, noncePrefix []byte, chunkSize int) (io.WriteCloser, error) {
+	checkStreamParams(aead, noncePrefix, chunkSize)
+	header := seekableHeader(noncePrefix, chunkSize)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return newStreamWriter(w, aead, noncePrefix, header, chunkSize), nil
+}
+
+// SeekableReader provides random access to a stream written by
+// NewSeekableWriter. Each ReadAt decrypts and authenticates only the
+// chunks that overlap the requested range. Wrap it in an
+// io.SectionReader for Read and Seek. A SeekableReader is safe for
+// concurrent use if the underlying io.ReaderAt is.
+type SeekableReader struct {
+	r         io.ReaderAt
+	aead      cipher.AEAD
+	header    []byte
+	prefix    []byte
+	chunkSize int64
+	chunks    int64
+	size      int64
+}
+
+// NewSeekableReader returns a SeekableReader for the size bytes of
+// encrypted data in r. It authenticates the header and the final
+// chunk up front, so a wrong key, a modified header or a truncated
+// stream is reported here rather than by a later ReadAt.
+func NewSeekableReader(r io.ReaderAt, size int64, aead cipher.AEAD) (*SeekableReader, error) {
+	fixed := make([]byte, len(seekableMagic)+6)
+	if _, err := r.ReadAt(fixed, 0); err != nil {
+		if err == io.EOF {
+			return nil, ErrSeekableHeader
+		}
+		return nil, err
+	}
+	if string(fixed[:len(seekableMagic)]) != seekableMagic || fixed[len(seekableMagic)] != seekableVersion {
+		return nil, ErrSeekableHeader
+	}
+	chunkSize := int64(binary.BigEndian.Uint32(fixed[len(seekableMagic)+1:]))
+	prefix := make([]byte, fixed[len(fixed)-1])
+	if _, err := r.ReadAt(prefix, int64(len(fixed))); err != nil {
+		if err == io.EOF {
+			return nil, ErrSeekableHeader
+		}
+		return nil, err
+	}
+	if chunkSize == 0 || len(prefix) != aead.NonceSize()-streamNonceOverhead {
+		return nil, ErrSeekableHeader
+	}
+
+	s := &SeekableReader{
+		r:         r,
+		aead:      aead,
+		header:    seekableHeader(prefix, int(chunkSize)),
+		prefix:    prefix,
+		chunkSize: chunkSize,
+	}
+	body := size - int64(len(s.header))
+	if body < int64(aead.Overhead()) {
+		return nil, ErrStreamTruncated
+	}
+	full := chunkSize + int64(aead.Overhead())
+	s.chunks = (body + full - 1) / full
+	if body-(s.chunks-1)*full < int64(aead.Overhead()) {
+		// The last chunk is too short to even hold a tag.
+		return nil, ErrOpen
+	}
+	if s.chunks-1 > int64(^uint32(0)) {
+		return nil, ErrStreamTooLong
+	}
+	s.size = body - s.chunks*int64(aead.Overhead())
+	if _, err := s.chunk(nil, s.chunks-1); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Size returns the length of the plaintext.
+func (s *SeekableReader) Size() int64 {
+	return s.size
+}
+
+// chunk decrypts chunk i, appending the plaintext to dst.
+func (s *SeekableReader) chunk(dst []byte, i int64) ([]byte, error) {
+	full := s.chunkSize + int64(s.aead.Overhead())
+	off := int64(len(s.header)) + i*full
+	n := full
+	final := i == s.chunks-1
+	if final {
+		n = int64(len(s.header)) + s.size + s.chunks*int64(s.aead.Overhead()) - off
+	}
+	buf := make([]byte, n)
+	if _, err := s.r.ReadAt(buf, off); err != nil {
+		if err == io.EOF {
+			return nil, ErrStreamTruncated
+		}
+		return nil, err
+	}
+	out, err := s.aead.Open(dst, streamNonce(s.prefix, uint32(i), final), buf, s.header)
+	if err != nil {
+		if final {
+			if _, err := s.aead.Open(nil, streamNonce(s.prefix, uint32(i), false), buf, s.header); err == nil {
+				return nil, ErrStreamTruncated
+			}
+		}
+		return nil, ErrOpen
+	}
+	return out, nil
+}
+
+// ReadAt implements io.ReaderAt. It returns ErrOpen if any chunk
+// overlapping the range fails authentication.
+func (s *SeekableReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("simonspeck: negative offset")
+	}
+	if off >= s.size {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+	n := 0
+	plain := make([]byte, 0, s.chunkSize)
+	for n < len(p) && off < s.size {
+		i := off / s.chunkSize
+		out, err := s.chunk(plain[:0], i)
+		if err != nil {
+			return n, err
+		}
+		c := copy(p[n:], out[off-i*s.chunkSize:])
+		n += c
+		off += int64(c)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
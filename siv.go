@@ -0,0 +1,113 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package simonspeck
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+)
+
+// sivSize is the length of the synthetic IV, which doubles as the tag.
+const sivSize = 16
+
+// SIV implements the deterministic authenticated encryption mode of
+// RFC 5297 with a 128-bit block cipher (Speck128 or Simon128) in
+// place of AES. It satisfies cipher.AEAD. Without a nonce it is
+// deterministic: equal inputs give equal ciphertexts, and nothing
+// else leaks. With a nonce it is an ordinary nonce-based AEAD that
+// stays secure even if nonces repeat.
+type SIV struct {
+	mac *cmac
+	ctr cipher.Block
+}
+
+// NewSIV returns an SIV that uses macBlock for S2V and ctrBlock for
+// CTR encryption; RFC 5297 keys them independently, with the first
+// and second halves of the SIV key. Both must have a 128-bit block
+// length.
+func NewSIV(macBlock, ctrBlock cipher.Block) *SIV {
+	if macBlock.BlockSize() != sivSize || ctrBlock.BlockSize() != sivSize {
+		panic("NewSIV() requires 128-bit block ciphers")
+	}
+	return &SIV{mac: newCMAC(macBlock), ctr: ctrBlock}
+}
+
+// NonceSize returns the recommended nonce length. Seal and Open accept
+// nonces of any length, including none at all for deterministic
+// encryption.
+func (s *SIV) NonceSize() int { return 16 }
+
+// Overhead returns the length of the synthetic IV.
+func (s *SIV) Overhead() int { return sivSize }
+
+// s2v computes the S2V function over the additional data, the nonce
+// if there is one, and the plaintext.
+func (s *SIV) s2v(additionalData, nonce, plaintext []byte) []byte {
+	m := s.mac.fresh()
+	m.Write(make([]byte, sivSize))
+	d := m.Sum(nil)
+	components := [][]byte{additionalData}
+	if len(nonce) > 0 {
+		components = append(components, nonce)
+	}
+	for _, c := range components {
+		m = s.mac.fresh()
+		m.Write(c)
+		gfDouble(d, d)
+		xorBytes(d, d, m.Sum(nil))
+	}
+	m = s.mac.fresh()
+	if len(plaintext) >= sivSize {
+		m.Write(plaintext[:len(plaintext)-sivSize])
+		last := make([]byte, sivSize)
+		xorBytes(last, plaintext[len(plaintext)-sivSize:], d)
+		m.Write(last)
+	} else {
+		gfDouble(d, d)
+		last := make([]byte, sivSize)
+		copy(last, plaintext)
+		last[len(plaintext)] = 0x80
+		xorBytes(last, last, d)
+		m.Write(last)
+	}
+	return m.Sum(nil)
+}
+
+// ctrStream returns the CTR keystream for the synthetic IV v, with
+// the two bits that RFC 5297 clears so that implementations can use
+// 32- or 64-bit counter arithmetic.
+func (s *SIV) ctrStream(v []byte) cipher.Stream {
+	q := append([]byte(nil), v...)
+	q[8] &= 0x7f
+	q[12] &= 0x7f
+	return cipher.NewCTR(s.ctr, q)
+}
+
+// Seal encrypts and authenticates plaintext, authenticates
+// additionalData and appends the result, the synthetic IV followed by
+// the ciphertext, to dst. Dst and plaintext must not overlap.
+func (s *SIV) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	v := s.s2v(additionalData, nonce, plaintext)
+	ret, out := sliceForAppend(dst, sivSize+len(plaintext))
+	s.ctrStream(v).XORKeyStream(out[sivSize:], plaintext)
+	copy(out, v)
+	return ret
+}
+
+// Open reverses Seal. It returns ErrOpen if the ciphertext, nonce or
+// additional data have been altered.
+func (s *SIV) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < sivSize {
+		return nil, ErrOpen
+	}
+	v := ciphertext[:sivSize]
+	plaintext := make([]byte, len(ciphertext)-sivSize)
+	s.ctrStream(v).XORKeyStream(plaintext, ciphertext[sivSize:])
+	if subtle.ConstantTimeCompare(s.s2v(additionalData, nonce, plaintext), v) != 1 {
+		clear(plaintext)
+		return nil, ErrOpen
+	}
+	return append(dst, plaintext...), nil
+}
@@ -0,0 +1,218 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package simonspeck
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"errors"
+	"io"
+)
+
+// streamNonceOverhead is the part of each nonce used by STREAM for
+// the chunk counter (4 bytes) and the final-chunk flag (1 byte).
+const streamNonceOverhead = 5
+
+var (
+	// ErrStreamTruncated is returned when a stream ends before its final chunk.
+	ErrStreamTruncated = errors.New("simonspeck: encrypted stream is truncated")
+	// ErrStreamTooLong is returned when a stream exceeds the chunk counter.
+	ErrStreamTooLong = errors.New("simonspeck: too many chunks for one stream")
+	// ErrStreamClosed is returned by Write after Close.
+	ErrStreamClosed = errors.New("simonspeck: write to closed stream")
+)
+
+// streamNonce returns the STREAM nonce for a chunk: the prefix, the
+// big-endian chunk counter and a byte that is 1 for the final chunk.
+func streamNonce(prefix []byte, counter uint32, final bool) []byte {
+	nonce := make([]byte, len(prefix)+streamNonceOverhead)
+	copy(nonce, prefix)
+	n := len(prefix)
+	nonce[n] = byte(counter >> 24)
+	nonce[n+1] = byte(counter >> 16)
+	nonce[n+2] = byte(counter >> 8)
+	nonce[n+3] = byte(counter)
+	if final {
+		nonce[n+4] = 1
+	}
+	return nonce
+}
+
+func checkStreamParams(aead cipher.AEAD, noncePrefix []byte, chunkSize int) {
+	if len(noncePrefix) != aead.NonceSize()-streamNonceOverhead {
+		panic("simonspeck: STREAM nonce prefix must be 5 bytes shorter than the AEAD nonce")
+	}
+	if chunkSize <= 0 {
+		panic("simonspeck: STREAM chunk size must be positive")
+	}
+}
+
+// streamWriter implements the encrypting side of the STREAM
+// construction of Hoang, Reyhanitabar, Rogaway and Vizár [1].
+//
+// [1]: https://eprint.iacr.org/2015/189
+type streamWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	prefix  []byte
+	ad      []byte
+	buf     []byte // plaintext of the current chunk
+	out     []byte // sealed chunk; some AEADs, such as SIV, cannot seal in place
+	n       int
+	counter uint32
+	err     error
+}
+
+// NewStreamWriter returns an io.WriteCloser that splits everything
+// written to it into chunks of chunkSize bytes and writes each one to
+// w sealed with aead, for example cipher.NewGCM(NewSpeck128(key)).
+// Chunk i is sealed under the nonce noncePrefix || i || final, where i
+// is a 4-byte counter and final is a byte set only for the last chunk,
+// so dropping, reordering or truncating chunks is detected by the
+// reader. The prefix must be aead.NonceSize()-5 bytes long and must
+// never be reused with the same key. Close seals the final chunk; it
+// does not close w.
+func NewStreamWriter(w io.Writer, aead cipher.AEAD, noncePrefix []byte, chunkSize int) io.WriteCloser {
+	return newStreamWriter(w, aead, noncePrefix, nil, chunkSize)
+}
+
+func newStreamWriter(w io.Writer, aead cipher.AEAD, noncePrefix, ad []byte, chunkSize int) *streamWriter {
+	checkStreamParams(aead, noncePrefix, chunkSize)
+	return &streamWriter{
+		w:      w,
+		aead:   aead,
+		prefix: append([]byte(nil), noncePrefix...),
+		ad:     ad,
+		buf:    make([]byte, chunkSize),
+		out:    make([]byte, 0, chunkSize+aead.Overhead()),
+	}
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	written := 0
+	for len(p) > 0 {
+		// A full chunk is only sealed once more data arrives, since
+		// until then it might turn out to be the final one.
+		if s.n == len(s.buf) {
+			if err := s.seal(false); err != nil {
+				return written, err
+			}
+		}
+		c := copy(s.buf[s.n:], p)
+		s.n += c
+		written += c
+		p = p[c:]
+	}
+	return written, nil
+}
+
+func (s *streamWriter) Close() error {
+	if s.err != nil {
+		if s.err == ErrStreamClosed {
+			return nil
+		}
+		return s.err
+	}
+	if err := s.seal(true); err != nil {
+		return err
+	}
+	s.err = ErrStreamClosed
+	return nil
+}
+
+func (s *streamWriter) seal(final bool) error {
+	if s.counter == ^uint32(0) && !final {
+		s.err = ErrStreamTooLong
+		return s.err
+	}
+	s.out = s.aead.Seal(s.out[:0], streamNonce(s.prefix, s.counter, final), s.buf[:s.n], s.ad)
+	if _, err := s.w.Write(s.out); err != nil {
+		s.err = err
+		return err
+	}
+	s.n = 0
+	s.counter++
+	return nil
+}
+
+type streamReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	buf     []byte
+	out     []byte
+	plain   []byte
+	counter uint32
+	err     error
+}
+
+// NewStreamReader returns an io.Reader that decrypts and
+// authenticates a stream written by NewStreamWriter with the same
+// aead, nonce prefix and chunk size. Read never returns
+// unauthenticated plaintext. It returns ErrOpen if a chunk has been
+// tampered with, moved or dropped, and ErrStreamTruncated if the
+// stream ends before its final chunk.
+func NewStreamReader(r io.Reader, aead cipher.AEAD, noncePrefix []byte, chunkSize int) io.Reader {
+	checkStreamParams(aead, noncePrefix, chunkSize)
+	return &streamReader{
+		r:      bufio.NewReader(r),
+		aead:   aead,
+		prefix: append([]byte(nil), noncePrefix...),
+		buf:    make([]byte, chunkSize+aead.Overhead()),
+		out:    make([]byte, chunkSize),
+	}
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	for len(s.plain) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		s.err = s.next()
+	}
+	n := copy(p, s.plain)
+	s.plain = s.plain[n:]
+	return n, nil
+}
+
+// next decrypts the next chunk into s.plain. It returns io.EOF after
+// the final chunk.
+func (s *streamReader) next() error {
+	n, err := io.ReadFull(s.r, s.buf)
+	if err == io.EOF {
+		return ErrStreamTruncated
+	}
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	final := n < len(s.buf)
+	if !final {
+		if _, err := s.r.Peek(1); err == io.EOF {
+			final = true
+		} else if err != nil {
+			return err
+		}
+	}
+	plain, err := s.aead.Open(s.out[:0], streamNonce(s.prefix, s.counter, final), s.buf[:n], nil)
+	if err != nil {
+		// If the last chunk we have is a valid non-final chunk, the
+		// stream was cut at a chunk boundary.
+		if final {
+			if _, err := s.aead.Open(nil, streamNonce(s.prefix, s.counter, false), s.buf[:n], nil); err == nil {
+				return ErrStreamTruncated
+			}
+		}
+		return ErrOpen
+	}
+	s.plain = plain
+	s.counter++
+	if final {
+		return io.EOF
+	}
+	return nil
+}
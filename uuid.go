@@ -0,0 +1,75 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package simonspeck
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+)
+
+const (
+	uuidVersionMask = 0x000000000000f000 // in the high word
+	uuidVariantMask = 0xc000000000000000 // in the low word
+	mask61          = 1<<61 - 1
+)
+
+// UUIDCipher encrypts 16-byte UUIDs into UUIDs with the same RFC 9562
+// version and variant bits, which hides e.g. the timestamp of a
+// UUIDv7. Only the 122 remaining bits are encrypted, with a Feistel
+// network over two 61-bit halves that uses a 128-bit block cipher
+// (Speck128 or Simon128) as its round function; a single block
+// encryption could not stay inside that domain.
+type UUIDCipher struct {
+	f feistel
+}
+
+// NewUUIDCipher creates and returns a new UUIDCipher. The block
+// cipher must have a 128-bit block length.
+func NewUUIDCipher(block cipher.Block) *UUIDCipher {
+	if block.BlockSize() != 16 {
+		panic("NewUUIDCipher() requires a 128-bit block cipher")
+	}
+	return &UUIDCipher{f: feistel{block: block, bits: 61}}
+}
+
+// Encrypt encrypts the 16-byte UUID in src into dst.
+// Dst and src may point at the same memory.
+func (c *UUIDCipher) Encrypt(dst, src []byte) {
+	c.crypt(dst, src, true)
+}
+
+// Decrypt decrypts the 16-byte UUID in src into dst.
+// Dst and src may point at the same memory.
+func (c *UUIDCipher) Decrypt(dst, src []byte) {
+	c.crypt(dst, src, false)
+}
+
+func (c *UUIDCipher) crypt(dst, src []byte, encrypt bool) {
+	if len(src) < 16 || len(dst) < 16 {
+		panic("simonspeck: UUID must be 16 bytes")
+	}
+	hi := binary.BigEndian.Uint64(src[0:8])
+	lo := binary.BigEndian.Uint64(src[8:16])
+	fixedHi, fixedLo := hi&uuidVersionMask, lo&uuidVariantMask
+
+	// Squeeze out the version nibble, leaving 60 free bits in hi and
+	// 62 in lo, then regroup them as 61 + 61.
+	hi = (hi>>16)<<12 | hi&0xfff
+	lo &^= uuidVariantMask
+	l := hi<<1 | lo>>61
+	r := lo & mask61
+	if encrypt {
+		l, r = c.f.encrypt(nil, l, r)
+	} else {
+		l, r = c.f.decrypt(nil, l, r)
+	}
+	hi = l >> 1
+	lo = (l&1)<<61 | r
+
+	hi = (hi>>12)<<16 | hi&0xfff | fixedHi
+	lo |= fixedLo
+	binary.BigEndian.PutUint64(dst[0:8], hi)
+	binary.BigEndian.PutUint64(dst[8:16], lo)
+}
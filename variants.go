@@ -0,0 +1,67 @@
+// Copyright 2026 The simonspeck Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package simonspeck
+
+import "crypto/cipher"
+
+// Variant describes one member of the Simon and Speck families by the
+// name used in the paper, e.g. "Speck128/256" for Speck with a 128-bit
+// block and a 256-bit key. Sizes are in bytes.
+type Variant struct {
+	Name      string
+	BlockSize int
+	KeySize   int
+	New       func(key []byte) cipher.Block
+}
+
+func newSimon32(key []byte) cipher.Block  { return NewSimon32(key) }
+func newSimon48(key []byte) cipher.Block  { return NewSimon48(key) }
+func newSimon64(key []byte) cipher.Block  { return NewSimon64(key) }
+func newSimon96(key []byte) cipher.Block  { return NewSimon96(key) }
+func newSimon128(key []byte) cipher.Block { return NewSimon128(key) }
+func newSpeck32(key []byte) cipher.Block  { return NewSpeck32(key) }
+func newSpeck48(key []byte) cipher.Block  { return NewSpeck48(key) }
+func newSpeck64(key []byte) cipher.Block  { return NewSpeck64(key) }
+func newSpeck96(key []byte) cipher.Block  { return NewSpeck96(key) }
+func newSpeck128(key []byte) cipher.Block { return NewSpeck128(key) }
+
+var variants = []Variant{
+	{"Simon32/64", 4, 8, newSimon32},
+	{"Simon48/72", 6, 9, newSimon48},
+	{"Simon48/96", 6, 12, newSimon48},
+	{"Simon64/96", 8, 12, newSimon64},
+	{"Simon64/128", 8, 16, newSimon64},
+	{"Simon96/96", 12, 12, newSimon96},
+	{"Simon96/144", 12, 18, newSimon96},
+	{"Simon128/128", 16, 16, newSimon128},
+	{"Simon128/192", 16, 24, newSimon128},
+	{"Simon128/256", 16, 32, newSimon128},
+	{"Speck32/64", 4, 8, newSpeck32},
+	{"Speck48/72", 6, 9, newSpeck48},
+	{"Speck48/96", 6, 12, newSpeck48},
+	{"Speck64/96", 8, 12, newSpeck64},
+	{"Speck64/128", 8, 16, newSpeck64},
+	{"Speck96/96", 12, 12, newSpeck96},
+	{"Speck96/144", 12, 18, newSpeck96},
+	{"Speck128/128", 16, 16, newSpeck128},
+	{"Speck128/192", 16, 24, newSpeck128},
+	{"Speck128/256", 16, 32, newSpeck128},
+}
+
+// Variants returns every Simon and Speck variant implemented by this
+// package.
+func Variants() []Variant {
+	return append([]Variant(nil), variants...)
+}
+
+// LookupVariant returns the variant with the given name.
+func LookupVariant(name string) (Variant, bool) {
+	for _, v := range variants {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return Variant{}, false
+}